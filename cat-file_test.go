@@ -160,6 +160,32 @@ func Test_ParseBlob(t *testing.T) {
 
 }
 
+func Test_ParseTag(t *testing.T) {
+	const inputSha = SHA("49bac2b0a923fe6481c7cc207837cf663748c1ed")
+	tm, err := time.Parse(RFC2822, "Thu Apr 9 16:40:07 2015 -0400")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Tag{
+		_type:      "tag",
+		Name:       inputSha,
+		Object:     "37213e7bb3c334a0f7708c7afcab5babb3f95434",
+		ObjectType: "commit",
+		Tag:        "0.1",
+		Tagger:     "aditya <dev@chimeracoder.net>",
+		TaggerDate: tm,
+		Message:    []byte("First implementation of the cli\n"),
+		size:       "155",
+	}
+	result, err := NewObject(inputSha, *RepoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected and result don't match:\n\n%+v\n\n%+v", expected, result)
+	}
+}
+
 func Test_ParsePackfile(t *testing.T) {
 	const inputSha = SHA("c3b8133617bbdb72e237b0f163fade7fbf1f0c18")
 	const expected = 2160
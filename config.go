@@ -0,0 +1,255 @@
+package gitgo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config holds the values set in a git config file.
+// Keys are of the form "section.name" or "section.subsection.name".
+// Section and variable names are case-insensitive, but subsection names are not.
+// A variable may be set more than once, so each key maps to every value
+// in the order in which they appear.
+type Config map[string][]string
+
+// Get returns the last value set for key, which is the value
+// git uses for single-valued variables
+func (c Config) Get(key string) (string, bool) {
+	values := c[normalizeConfigKey(key)]
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[len(values)-1], true
+}
+
+// GetAll returns every value set for key
+func (c Config) GetAll(key string) []string {
+	return c[normalizeConfigKey(key)]
+}
+
+// Bool returns the boolean value of key. If key is not set, it returns false.
+func (c Config) Bool(key string) (bool, error) {
+	value, ok := c.Get(key)
+	if !ok {
+		return false, nil
+	}
+	b, err := parseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean value for %s: %s", key, value)
+	}
+	return b, nil
+}
+
+// parseBool parses a boolean using the same rules as git
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean value: %s", value)
+}
+
+// normalizeConfigKey lowercases the section and variable name of key,
+// leaving the subsection (if any) alone
+func normalizeConfigKey(key string) string {
+	first := strings.Index(key, ".")
+	last := strings.LastIndex(key, ".")
+	if first < 0 {
+		return strings.ToLower(key)
+	}
+	return strings.ToLower(key[:first]) + key[first:last] + strings.ToLower(key[last:])
+}
+
+// Config returns the repository's configuration, read from .git/config.
+// If extensions.worktreeConfig is enabled, the settings in the worktree's
+// config.worktree file are layered on top, overriding the shared settings.
+func (r *Repository) Config() (Config, error) {
+	dir, err := r.gitDir()
+	if err != nil {
+		return nil, err
+	}
+	common, err := commonDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	config, err := readConfigFile(filepath.Join(common, "config"))
+	if err != nil {
+		return nil, err
+	}
+
+	worktreeConfig, err := config.Bool("extensions.worktreeConfig")
+	if err != nil || !worktreeConfig {
+		return config, err
+	}
+	overlay, err := readConfigFile(filepath.Join(dir, "config.worktree"))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range overlay {
+		config[key] = append(config[key], values...)
+	}
+	return config, nil
+}
+
+// readConfigFile parses the config file at path.
+// If the file does not exist, the config is empty.
+func readConfigFile(path string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Config{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
+
+// ReadConfig parses a file in the git config format
+func ReadConfig(r io.Reader) (Config, error) {
+	config := Config{}
+	var section string
+
+	scnr := bufio.NewScanner(r)
+	var lineno int
+	for scnr.Scan() {
+		lineno++
+		line := scnr.Text()
+
+		// a trailing backslash continues the line
+		for strings.HasSuffix(line, "\\") && !strings.HasSuffix(line, "\\\\") && scnr.Scan() {
+			lineno++
+			line = line[:len(line)-1] + scnr.Text()
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			var err error
+			section, line, err = parseConfigSection(line)
+			if err != nil {
+				return nil, fmt.Errorf("bad config line %d: %s", lineno, err)
+			}
+			line = strings.TrimSpace(line)
+			if line == "" || line[0] == '#' || line[0] == ';' {
+				continue
+			}
+		}
+
+		if section == "" {
+			return nil, fmt.Errorf("bad config line %d: variable outside of any section", lineno)
+		}
+
+		// a variable without a value is a boolean set to true
+		name := line
+		if i := strings.IndexAny(name, "#;"); i >= 0 {
+			name = strings.TrimSpace(name[:i])
+		}
+		value := "true"
+		if i := strings.Index(line, "="); i >= 0 {
+			name = strings.TrimSpace(line[:i])
+			var err error
+			value, err = parseConfigValue(line[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("bad config line %d: %s", lineno, err)
+			}
+		}
+		key := section + "." + strings.ToLower(name)
+		config[key] = append(config[key], value)
+	}
+	return config, scnr.Err()
+}
+
+// parseConfigSection parses a section header, such as [core], [remote "origin"],
+// or the deprecated [branch.master]. It returns the normalized section name
+// and anything that follows the header on the same line
+func parseConfigSection(line string) (section string, remainder string, err error) {
+	end := strings.LastIndex(line, "]")
+	if end < 0 {
+		return "", "", fmt.Errorf("unterminated section header")
+	}
+	header := line[1:end]
+	remainder = line[end+1:]
+
+	i := strings.IndexAny(header, " \t")
+	if i < 0 {
+		// the deprecated [section.subsection] syntax
+		// also lowercases the subsection
+		return strings.ToLower(header), remainder, nil
+	}
+
+	name := strings.ToLower(header[:i])
+	subsection := strings.TrimSpace(header[i:])
+	if len(subsection) < 2 || subsection[0] != '"' || subsection[len(subsection)-1] != '"' {
+		return "", "", fmt.Errorf("malformed subsection: %s", subsection)
+	}
+	subsection = subsection[1 : len(subsection)-1]
+	subsection = strings.Replace(subsection, `\"`, `"`, -1)
+	subsection = strings.Replace(subsection, `\\`, `\`, -1)
+	return name + "." + subsection, remainder, nil
+}
+
+// parseConfigValue handles quoting, escape sequences,
+// and comments within the value of a config variable
+func parseConfigValue(raw string) (string, error) {
+	var value bytes.Buffer
+	var quoted bool
+	// whitespace is only kept if it is followed by something
+	// other than a comment or the end of the line
+	var pendingSpace bytes.Buffer
+
+	raw = strings.TrimLeft(raw, " \t")
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+			value.Write(pendingSpace.Bytes())
+			pendingSpace.Reset()
+			continue
+		case !quoted && (c == '#' || c == ';'):
+			return value.String(), nil
+		case !quoted && (c == ' ' || c == '\t'):
+			pendingSpace.WriteByte(c)
+			continue
+		}
+
+		value.Write(pendingSpace.Bytes())
+		pendingSpace.Reset()
+
+		if c != '\\' {
+			value.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(raw) {
+			return "", fmt.Errorf("trailing backslash")
+		}
+		switch raw[i] {
+		case 'n':
+			value.WriteByte('\n')
+		case 't':
+			value.WriteByte('\t')
+		case 'b':
+			value.WriteByte('\b')
+		case '\\', '"':
+			value.WriteByte(raw[i])
+		default:
+			return "", fmt.Errorf("invalid escape sequence \\%c", raw[i])
+		}
+	}
+	if quoted {
+		return "", fmt.Errorf("unterminated quoted value")
+	}
+	return value.String(), nil
+}
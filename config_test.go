@@ -0,0 +1,138 @@
+package gitgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_RepositoryConfig(t *testing.T) {
+	repo := &Repository{Basedir: *RepoDir}
+	config, err := repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"core.bare":                    "false",
+		"Core.IgnoreCase":              "true",
+		"remote.origin.url":            "git@github.com:ChimeraCoder/gitgo.git",
+		"branch.master.merge":          "refs/heads/master",
+		"remote.origin.fetch":          "+refs/heads/*:refs/remotes/origin/*",
+		"core.repositoryformatversion": "0",
+	}
+	for key, expected := range cases {
+		if result, _ := config.Get(key); result != expected {
+			t.Errorf("%s: expected %q and received %q", key, expected, result)
+		}
+	}
+}
+
+func Test_ReadConfig(t *testing.T) {
+	const input = `# comment
+[core]
+	bare ; a comment
+	editor = "vim -f" # another comment
+	pager = less \
+	-R
+[remote "Origin"]
+	url = "C:\\path\twith \"quotes\""
+	fetch = +refs/heads/*:refs/remotes/Origin/*
+	fetch = +refs/tags/*:refs/tags/*
+[Branch.Master]
+	remote = origin
+`
+	expected := Config{
+		"core.bare":            {"true"},
+		"core.editor":          {"vim -f"},
+		"core.pager":           {"less \t-R"},
+		"remote.Origin.url":    {"C:\\path\twith \"quotes\""},
+		"remote.Origin.fetch":  {"+refs/heads/*:refs/remotes/Origin/*", "+refs/tags/*:refs/tags/*"},
+		"branch.master.remote": {"origin"},
+	}
+	config, err := ReadConfig(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, config) {
+		t.Errorf("Expected and result don't match:\n%q\n%q", expected, config)
+	}
+	if _, ok := config.Get("remote.origin.url"); ok {
+		t.Errorf("subsection names should be case-sensitive")
+	}
+	if bare, err := config.Bool("CORE.BARE"); err != nil || !bare {
+		t.Errorf("expected core.bare to be true: %v", err)
+	}
+}
+
+func Test_WorktreeConfig(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+	dir := repo.Basedir.Name()
+
+	// a linked worktree, as created by git worktree add
+	worktreeDir := filepath.Join(dir, "worktrees", "wt")
+	if err := os.MkdirAll(worktreeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(worktreeDir, "HEAD"):            "ref: refs/heads/wt\n",
+		filepath.Join(worktreeDir, "commondir"):       "../..\n",
+		filepath.Join(worktreeDir, "config.worktree"): "[core]\n\tsparseCheckout = true\n\tignorecase = false\n",
+		filepath.Join(dir, "config.worktree"):         "[core]\n\tsparseCheckout = false\n",
+	}
+	for path, contents := range files {
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Open(worktreeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked := &Repository{Basedir: *f}
+
+	// config.worktree is ignored unless extensions.worktreeConfig is set
+	config, err := linked.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := config.Get("core.sparseCheckout"); ok {
+		t.Errorf("expected core.sparseCheckout to be unset")
+	}
+	if result, _ := config.Get("remote.origin.url"); result != "git@github.com:ChimeraCoder/gitgo.git" {
+		t.Errorf("expected the shared config and received remote.origin.url %q", result)
+	}
+
+	shared, err := os.OpenFile(filepath.Join(dir, "config"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shared.WriteString("[extensions]\n\tworktreeConfig = true\n"); err != nil {
+		t.Fatal(err)
+	}
+	shared.Close()
+
+	cases := []struct {
+		repo     *Repository
+		key      string
+		expected string
+	}{
+		{linked, "core.sparseCheckout", "true"},
+		{linked, "core.ignorecase", "false"},
+		{linked, "remote.origin.url", "git@github.com:ChimeraCoder/gitgo.git"},
+		{repo, "core.sparseCheckout", "false"},
+		{repo, "core.ignorecase", "true"},
+	}
+	for _, c := range cases {
+		config, err := c.repo.Config()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result, _ := config.Get(c.key); result != c.expected {
+			t.Errorf("%s: expected %q and received %q", c.key, c.expected, result)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package gitgo
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A CorruptObject is an object reported by ScanForCorruption
+type CorruptObject struct {
+	Name SHA
+
+	// Path is the loose object file or packfile where the object is stored.
+	// For packed objects, Offset is the offset of the object in the packfile.
+	Path   string
+	Offset int
+
+	Reason string
+}
+
+func (c CorruptObject) String() string {
+	if strings.HasSuffix(c.Path, ".pack") {
+		return fmt.Sprintf("%s (%s at offset %d): %s", c.Name, c.Path, c.Offset, c.Reason)
+	}
+	return fmt.Sprintf("%s (%s): %s", c.Name, c.Path, c.Reason)
+}
+
+// ScanForCorruption reads every loose and packed object in the repository,
+// and reports each object that cannot be inflated, cannot be parsed, or
+// whose contents do not hash to its name. Objects are read one at a time,
+// so a corrupt object does not prevent the rest from being checked.
+// (Content hashes are only checked for SHA-1 object names.)
+//
+// The error is only non-nil if the object directories themselves cannot
+// be read. If a packfile's index cannot be read, the packfile is reported
+// as a CorruptObject with no name.
+func ScanForCorruption(repo *Repository) ([]CorruptObject, error) {
+	dir, err := repo.commonGitDir()
+	if err != nil {
+		return nil, err
+	}
+	objectsDir := filepath.Join(dir, "objects")
+
+	var corrupt []CorruptObject
+	dirs, err := ioutil.ReadDir(objectsDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dirs {
+		if !d.IsDir() || len(d.Name()) != 2 || strings.Trim(d.Name(), "0123456789abcdef") != "" {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(objectsDir, d.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			name := SHA(d.Name() + file.Name())
+			if !validObjectName(name) {
+				// temporary files, such as those left by an interrupted write
+				continue
+			}
+			path := filepath.Join(objectsDir, d.Name(), file.Name())
+			if reason := checkLooseObject(path, name); reason != "" {
+				corrupt = append(corrupt, CorruptObject{Name: name, Path: path, Reason: reason})
+			}
+		}
+	}
+
+	packDir := filepath.Join(objectsDir, "pack")
+	files, err := ioutil.ReadDir(packDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".pack") {
+			continue
+		}
+		found, err := scanPackfile(filepath.Join(packDir, file.Name()), file.Size())
+		if err != nil {
+			return nil, err
+		}
+		corrupt = append(corrupt, found...)
+	}
+	return corrupt, nil
+}
+
+// checkLooseObject reads the loose object at path, and returns
+// the reason that it is corrupt, or "" if it is not
+func checkLooseObject(path string, name SHA) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return err.Error()
+	}
+	defer f.Close()
+
+	zr, err := inflateLooseObject(f)
+	if err != nil {
+		return fmt.Sprintf("could not inflate: %s", err)
+	}
+	objType, size, err := readLooseHeader(zr)
+	if err != nil {
+		return fmt.Sprintf("could not read header: %s", err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return fmt.Sprintf("could not inflate: %s", err)
+	}
+	if size != strconv.Itoa(len(data)) {
+		return fmt.Sprintf("header records size %s, but contents are %d bytes", size, len(data))
+	}
+	return checkObjectContents(name, objType, data)
+}
+
+// scanPackfile reads every object in the packfile at path (which is size
+// bytes long) and returns the objects that are corrupt. Each object is
+// located using the index, so that one corrupt object does not prevent
+// the objects that follow it from being read.
+func scanPackfile(path string, size int64) ([]CorruptObject, error) {
+	pack, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer pack.Close()
+
+	idxPath := strings.TrimSuffix(path, ".pack") + ".idx"
+	idx, err := os.Open(idxPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []CorruptObject{{Path: path, Reason: "missing index"}}, nil
+		}
+		return nil, err
+	}
+	defer idx.Close()
+
+	objects, err := NewRemotePack(pack, size, idx)
+	if err != nil {
+		return []CorruptObject{{Path: idxPath, Reason: err.Error()}}, nil
+	}
+
+	var names []SHA
+	for name := range objects.objects {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return objects.objects[names[i]].Offset < objects.objects[names[j]].Offset
+	})
+
+	var corrupt []CorruptObject
+	for _, name := range names {
+		report := CorruptObject{Name: name, Path: path, Offset: objects.objects[name].Offset}
+		var object *packObject
+		err := recoverError(func() (err error) {
+			object, err = objects.resolve(name, 0)
+			return err
+		})
+		if err != nil {
+			report.Reason = err.Error()
+			corrupt = append(corrupt, report)
+			continue
+		}
+		if reason := checkObjectContents(name, object.Type(), object.PatchedData); reason != "" {
+			report.Reason = reason
+			corrupt = append(corrupt, report)
+		}
+	}
+	return corrupt, nil
+}
+
+// checkObjectContents returns the reason that an object with the given
+// name, type, and contents is corrupt, or "" if it is not
+func checkObjectContents(name SHA, objType string, data []byte) string {
+	if len(name) == 40 {
+		if actual := hashObject(objType, data, 20); actual != name {
+			return fmt.Sprintf("contents hash to %s", actual)
+		}
+	}
+
+	size := strconv.Itoa(len(data))
+	var parse func() error
+	switch objType {
+	case "commit":
+		parse = func() (err error) {
+			_, err = parseCommit(bytes.NewReader(data), size, name)
+			return err
+		}
+	case "tree":
+		parse = func() (err error) {
+			_, err = parseTree(bytes.NewReader(data), size)
+			return err
+		}
+	case "blob":
+		return ""
+	case "tag":
+		parse = func() (err error) {
+			_, err = parseTag(bytes.NewReader(data), size, name)
+			return err
+		}
+	default:
+		return fmt.Sprintf("unknown object type %q", objType)
+	}
+	if err := recoverError(parse); err != nil {
+		return fmt.Sprintf("could not parse %s: %s", objType, err)
+	}
+	return ""
+}
+
+// recoverError calls fn, and converts a panic into an error.
+// The parsers assume well-formed objects, and may panic on
+// corrupt input (such as a header line that is missing its value).
+func recoverError(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return fn()
+}
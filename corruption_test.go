@@ -0,0 +1,96 @@
+package gitgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_ScanForCorruption(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+	objectsDir := filepath.Join(repo.Basedir.Name(), "objects")
+
+	corrupt, err := ScanForCorruption(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corrupt) != 0 {
+		t.Fatalf("expected no corrupt objects and received %+v", corrupt)
+	}
+
+	loosePath := func(name SHA) string {
+		return filepath.Join(objectsDir, string(name[:2]), string(name[2:]))
+	}
+
+	// a loose object that is not compressed
+	const garbage = SHA("37213e7bb3c334a0f7708c7afcab5babb3f95434")
+	if err := os.Chmod(loosePath(garbage), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(loosePath(garbage), []byte("not an object"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a valid loose object stored under the wrong name
+	const misnamed = SHA("ffffffffffffffffffffffffffffffffffffffff")
+	contents, err := ioutil.ReadFile(loosePath("c9b4a98fd0720609e086293d53a28b1ad8d41c55"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(loosePath(misnamed)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(loosePath(misnamed), contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// damage a packed blob, which is also the base of
+	// two deltas (one of which is the base of another)
+	packPath := filepath.Join(objectsDir, "pack", "pack-d310969c4ba0ebfe725685fa577a1eec5ecb15b2.pack")
+	pack, err := ioutil.ReadFile(packPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 2000; i < 2100; i++ {
+		pack[i] ^= 0xff
+	}
+	if err := os.Chmod(packPath, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(packPath, pack, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt, err = ScanForCorruption(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []SHA
+	for _, c := range corrupt {
+		names = append(names, c.Name)
+		if c.Reason == "" {
+			t.Errorf("%s: no reason given", c.Name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	expected := []SHA{
+		"05d3cc770bd3524cc25d47e083d8942ad25033f0",
+		garbage,
+		"7147f43ae01c9f04a78d6e80544ed84def06e958",
+		"b45377f6daf59a4cec9e8de64f5df1533a7994cd",
+		"c3b8133617bbdb72e237b0f163fade7fbf1f0c18",
+		misnamed,
+	}
+	if !reflect.DeepEqual(expected, names) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, corrupt)
+	}
+	for _, c := range corrupt {
+		if c.Name == "7147f43ae01c9f04a78d6e80544ed84def06e958" && (c.Path != packPath || c.Offset != 1725) {
+			t.Errorf("expected %s at offset 1725 and received %s at offset %d", packPath, c.Path, c.Offset)
+		}
+	}
+}
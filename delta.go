@@ -5,25 +5,88 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 )
 
-// patchDelta will apply a delta to a base.
-func patchDelta(start io.ReadSeeker, delta io.Reader) (io.Reader, error) {
-	base := errReadSeeker{start, nil}
+// DeltaBaseCache holds the contents of delta base objects, keyed by name,
+// so that they can be shared between calls to VerifyPackCached.
+// This is useful when indexing many related packs (such as thin packs
+// from incremental fetches), whose bases often live in another pack.
+// It is safe for concurrent use, and the zero value is an empty cache.
+type DeltaBaseCache struct {
+	mu    sync.Mutex
+	bases map[SHA]deltaBase
+}
+
+type deltaBase struct {
+	_type packObjectType
+	data  []byte
+	// depth is the length of the base's own delta chain
+	depth int
+}
+
+func NewDeltaBaseCache() *DeltaBaseCache {
+	return &DeltaBaseCache{bases: map[SHA]deltaBase{}}
+}
+
+// Add adds the (fully patched) contents of an object to the cache
+func (c *DeltaBaseCache) Add(name SHA, objType packObjectType, data []byte) {
+	c.add(name, deltaBase{objType, data, 0})
+}
+
+func (c *DeltaBaseCache) add(name SHA, base deltaBase) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.bases == nil {
+		c.bases = map[SHA]deltaBase{}
+	}
+	c.bases[name] = base
+}
+
+func (c *DeltaBaseCache) get(name SHA) (deltaBase, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	base, ok := c.bases[name]
+	return base, ok
+}
+
+// Len returns the number of objects in the cache
+func (c *DeltaBaseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.bases)
+}
+
+// Clear removes all objects from the cache
+func (c *DeltaBaseCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bases = map[SHA]deltaBase{}
+}
+
+// A DeltaOp is a single instruction in a delta. It either copies Size
+// bytes of the base object, starting at Offset, or inserts Data.
+type DeltaOp struct {
+	Copy   bool
+	Offset int
+	Size   int
+	Data   []byte
+}
+
+// DecodeDeltaOps decodes a delta (as stored in a packfile), returning
+// the sizes of the base object and of the result of applying the delta,
+// along with the delta's instructions
+func DecodeDeltaOps(delta io.Reader) (baseSize, resultSize int, ops []DeltaOp, err error) {
 	deltar := newErrReader(delta)
 
 	// First, read the source and target lengths (varints)
 	// we can ignore err as long as we check deltar.err at the end
-	// we have no need for the targetLength right now, so we discard it
-	sourceLength, _ := parseVarInt(deltar)
-	_, _ = parseVarInt(deltar)
+	baseSize, _ = parseVarInt(deltar)
+	resultSize, _ = parseVarInt(deltar)
 	if deltar.err != nil {
-		return nil, deltar.err
+		return 0, 0, nil, deltar.err
 	}
 
-	result := bytes.NewBuffer(nil)
-	//result = io.MultiWriter(result, os.Stderr)
-
 	// Now, the rest of the bytes are either copy or insert instructions
 	// If the MSB is set, it is a copy
 
@@ -80,21 +143,12 @@ func patchDelta(start io.ReadSeeker, delta io.Reader) (io.Reader, error) {
 				numBytes = 65536
 			}
 
-			// read numBytes from source, starting at baseOffset
-			// and write that to the target
-			base.Seek(int64(baseOffset), os.SEEK_SET)
-			buf := make([]byte, numBytes)
-			base.read(buf)
-
-			_, err := result.Write(buf)
-			if err != nil {
-				return result, err
-			}
+			ops = append(ops, DeltaOp{Copy: true, Offset: baseOffset, Size: int(numBytes)})
 
 		case 0:
 			if b == 0 {
 				// cmd == 0 is reserved for future encoding extensions
-				return nil, fmt.Errorf("cannot process delta opcode 0")
+				return 0, 0, nil, fmt.Errorf("cannot process delta opcode 0")
 			}
 
 			// insert instruction
@@ -106,16 +160,43 @@ func patchDelta(start io.ReadSeeker, delta io.Reader) (io.Reader, error) {
 			numBytes := int(b)
 			buf := make([]byte, numBytes)
 			deltar.read(buf)
-			_, err := result.Write(buf)
-			if err != nil {
-				return nil, err
-			}
+			ops = append(ops, DeltaOp{Size: numBytes, Data: buf})
 
 		default:
-			return nil, fmt.Errorf("invalid opcode %08b", b)
+			return 0, 0, nil, fmt.Errorf("invalid opcode %08b", b)
 		}
 	}
 
+	if deltar.err == io.EOF {
+		return baseSize, resultSize, ops, nil
+	}
+	return baseSize, resultSize, ops, deltar.err
+}
+
+// patchDelta will apply a delta to a base.
+func patchDelta(start io.ReadSeeker, delta io.Reader) (io.Reader, error) {
+	base := errReadSeeker{start, nil}
+
+	sourceLength, _, ops, err := DecodeDeltaOps(delta)
+	if err != nil {
+		return nil, err
+	}
+
+	result := bytes.NewBuffer(nil)
+	for _, op := range ops {
+		if !op.Copy {
+			result.Write(op.Data)
+			continue
+		}
+
+		// read op.Size bytes from source, starting at op.Offset
+		// and write that to the target
+		base.Seek(int64(op.Offset), os.SEEK_SET)
+		buf := make([]byte, op.Size)
+		base.read(buf)
+		result.Write(buf)
+	}
+
 	n, err := base.Seek(0, os.SEEK_END)
 	if err != nil {
 		return nil, err
@@ -123,11 +204,62 @@ func patchDelta(start io.ReadSeeker, delta io.Reader) (io.Reader, error) {
 	if n != int64(sourceLength) {
 		return nil, fmt.Errorf("expected to read %d bytes and read %d", sourceLength, n)
 	}
+	return result, nil
+}
 
-	if deltar.err == io.EOF {
-		return result, nil
+// DumpDelta writes a description of how the object with the given name
+// is stored to w. If it is stored as a delta, this includes the base
+// object and the delta's instructions. This is useful for understanding
+// why an object does (or does not) deltify well.
+func DumpDelta(repo *Repository, name SHA, w io.Writer) error {
+	name, err := repo.expandSHA(name)
+	if err != nil {
+		return err
+	}
+	packfiles, err := repo.packs()
+	if err != nil {
+		return err
+	}
+
+	var object *packObject
+	for _, pack := range packfiles {
+		if p, ok := pack.objects[name]; ok {
+			object = p
+			break
+		}
+	}
+	if object == nil {
+		if _, err := repo.ObjectSize(name); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(w, "%s is a loose object, which is not stored as a delta\n", name)
+		return err
+	}
+	if object._type < OBJ_OFS_DELTA {
+		_, err := fmt.Fprintf(w, "%s is stored in full (%s, %d bytes)\n", name, object._type, object.Size)
+		return err
+	}
+
+	baseSize, resultSize, ops, err := DecodeDeltaOps(bytes.NewReader(object.Data))
+	if err != nil {
+		return err
+	}
+
+	var copied, inserted int
+	fmt.Fprintf(w, "%s is stored as a delta (%s, depth %d)\n", name, object._type, object.Depth)
+	fmt.Fprintf(w, "base: %s (%d bytes)\n", object.BaseObjectName, baseSize)
+	fmt.Fprintf(w, "result: %d bytes\n", resultSize)
+	for _, op := range ops {
+		if op.Copy {
+			copied += op.Size
+			fmt.Fprintf(w, "copy   offset=%d size=%d\n", op.Offset, op.Size)
+		} else {
+			inserted += op.Size
+			fmt.Fprintf(w, "insert size=%d %q\n", op.Size, op.Data)
+		}
 	}
-	return result, deltar.err
+	_, err = fmt.Fprintf(w, "%d instructions: %d bytes copied, %d bytes inserted\n", len(ops), copied, inserted)
+	return err
 }
 
 func parseVarInt(r io.Reader) (int, error) {
@@ -110,3 +110,126 @@ func readersEqual(r1, r2 io.Reader) bool {
 	}
 	return true
 }
+
+func Test_DeltaBaseCache(t *testing.T) {
+	const baseName = SHA("3b18e512dba79e4c8300dd08aeb37f8e728b8dad")
+	// copy the first 6 bytes of the base, then insert "!\n"
+	delta := []byte{12, 8, 0x90, 6, 2, '!', '\n'}
+	newObject := func() *packObject {
+		return &packObject{_type: OBJ_REF_DELTA, BaseObjectName: baseName, Data: delta}
+	}
+
+	// the base is not in the pack
+	if err := newObject().patch(map[SHA]*packObject{}, nil); err == nil {
+		t.Errorf("expected error for missing base")
+	}
+
+	cache := NewDeltaBaseCache()
+	cache.Add(baseName, OBJ_BLOB, []byte("hello world\n"))
+	if cache.Len() != 1 {
+		t.Errorf("expected 1 cached base and received %d", cache.Len())
+	}
+
+	object := newObject()
+	if err := object.patch(map[SHA]*packObject{}, cache); err != nil {
+		t.Fatal(err)
+	}
+	if string(object.PatchedData) != "hello !\n" || object.BaseObjectType != OBJ_BLOB {
+		t.Errorf("expected patched blob %q and received %s %q", "hello !\n", object.BaseObjectType, object.PatchedData)
+	}
+
+	// bases that are resolved from the pack are added to the cache
+	base := &packObject{Name: "0000000000000000000000000000000000000001", _type: OBJ_BLOB, Data: []byte("hello there\n")}
+	object = newObject()
+	object.BaseObjectName = base.Name
+	if err := object.patch(map[SHA]*packObject{base.Name: base}, cache); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 cached bases and received %d", cache.Len())
+	}
+
+	// the depth of a cached base is kept
+	deep := &packObject{Name: "0000000000000000000000000000000000000002", _type: OBJ_BLOB, Data: []byte("hello again\n"), Depth: 3}
+	for i, dict := range []map[SHA]*packObject{{deep.Name: deep}, {}} {
+		object = newObject()
+		object.BaseObjectName = deep.Name
+		if err := object.patch(dict, cache); err != nil {
+			t.Fatal(err)
+		}
+		if object.Depth != deep.Depth {
+			t.Errorf("patch %d: expected depth %d and received %d", i, deep.Depth, object.Depth)
+		}
+	}
+
+	cache.Clear()
+	if cache.Len() != 0 {
+		t.Errorf("expected empty cache and received %d", cache.Len())
+	}
+
+	// the zero value is an empty cache
+	var zero DeltaBaseCache
+	zero.Add(baseName, OBJ_BLOB, []byte("hello world\n"))
+	if zero.Len() != 1 {
+		t.Errorf("expected 1 cached base and received %d", zero.Len())
+	}
+}
+
+func Test_DecodeDeltaOps(t *testing.T) {
+	// copy 6 bytes from offset 0x0102, then insert "!\n"
+	delta := []byte{12, 8, 0x93, 0x02, 0x01, 6, 2, '!', '\n'}
+	expected := []DeltaOp{
+		{Copy: true, Offset: 0x0102, Size: 6},
+		{Size: 2, Data: []byte("!\n")},
+	}
+
+	baseSize, resultSize, ops, err := DecodeDeltaOps(bytes.NewReader(delta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baseSize != 12 || resultSize != 8 {
+		t.Errorf("expected sizes 12 and 8 and received %d and %d", baseSize, resultSize)
+	}
+	if !reflect.DeepEqual(expected, ops) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, ops)
+	}
+}
+
+func Test_DumpDelta(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	const baseName = SHA("3b18e512dba79e4c8300dd08aeb37f8e728b8dad")
+	const name = SHA("0000000000000000000000000000000000000001")
+	packfiles, err := repo.packs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.packfiles = append(packfiles, &packfile{objects: map[SHA]*packObject{
+		name: {Name: name, _type: OBJ_REF_DELTA, BaseObjectName: baseName, Depth: 1, Data: []byte{12, 8, 0x90, 6, 2, '!', '\n'}},
+	}})
+
+	expected := `0000000000000000000000000000000000000001 is stored as a delta (OBJ_REF_DELTA, depth 1)
+base: 3b18e512dba79e4c8300dd08aeb37f8e728b8dad (12 bytes)
+result: 8 bytes
+copy   offset=0 size=6
+insert size=2 "!\n"
+2 instructions: 6 bytes copied, 2 bytes inserted
+`
+	var buf bytes.Buffer
+	if err := DumpDelta(repo, name, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Errorf("Expected and result don't match:\n%s\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := DumpDelta(repo, "fe89ee30bbcdfdf376beae530cc53f967012f31c", &buf); err != nil {
+		t.Fatal(err)
+	}
+	expected = "fe89ee30bbcdfdf376beae530cc53f967012f31c is stored in full (OBJ_COMMIT, 267 bytes)\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q and received %q", expected, buf.String())
+	}
+}
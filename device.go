@@ -0,0 +1,19 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package gitgo
+
+import (
+	"os"
+	"syscall"
+)
+
+// deviceID returns the ID of the device containing the file,
+// which is used to detect filesystem boundaries
+func deviceID(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Dev), true
+}
@@ -0,0 +1,14 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package gitgo
+
+import (
+	"os"
+)
+
+// deviceID is not supported on this platform, so
+// filesystem boundaries are not detected
+func deviceID(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
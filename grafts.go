@@ -0,0 +1,120 @@
+package gitgo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadGrafts parses an info/grafts file. Each line lists a commit,
+// followed by the parents that should be used in place of the commit's
+// real parents (possibly none, which makes the commit a root).
+// Blank lines and lines beginning with # are ignored.
+func ReadGrafts(r io.Reader) (map[SHA][]SHA, error) {
+	grafts := map[SHA][]SHA{}
+	scnr := bufio.NewScanner(r)
+	var lineno int
+	for scnr.Scan() {
+		lineno++
+		line := strings.TrimSpace(scnr.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		names := make([]SHA, len(fields))
+		for i, field := range fields {
+			names[i] = SHA(strings.ToLower(field))
+			if !validObjectName(names[i]) {
+				return nil, fmt.Errorf("bad graft line %d: %s", lineno, line)
+			}
+		}
+		grafts[names[0]] = names[1:]
+	}
+	return grafts, scnr.Err()
+}
+
+// graftsFile returns the path of the repository's grafts file,
+// which can be overridden with GIT_GRAFT_FILE
+func (r *Repository) graftsFile() (string, error) {
+	if path := os.Getenv("GIT_GRAFT_FILE"); path != "" {
+		return path, nil
+	}
+	dir, err := r.gitDir()
+	if err != nil {
+		return "", err
+	}
+	common, err := commonDir(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(common, "info", "grafts"), nil
+}
+
+// readGrafts returns the repository's grafts.
+// They are read the first time they are needed.
+func (r *Repository) readGrafts() (map[SHA][]SHA, error) {
+	if r.grafts != nil {
+		return r.grafts, nil
+	}
+	path, err := r.graftsFile()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		r.grafts = map[SHA][]SHA{}
+		return r.grafts, nil
+	}
+	defer f.Close()
+	grafts, err := ReadGrafts(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	r.grafts = grafts
+	return grafts, nil
+}
+
+// graft returns obj with its parents replaced by the
+// parents in its graft, if obj is a commit that has one
+func (r *Repository) graft(obj GitObject) (GitObject, error) {
+	commit, ok := obj.(Commit)
+	if !ok {
+		return obj, nil
+	}
+	grafts, err := r.readGrafts()
+	if err != nil || len(grafts) == 0 {
+		return obj, err
+	}
+	// a commit read by an abbreviated name is named by the abbreviation,
+	// but grafts are keyed by full name
+	name := commit.Name
+	if len(name) < 40 {
+		name, err = r.expandSHA(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	parents, ok := grafts[name]
+	if !ok {
+		return obj, nil
+	}
+	commit.Parents = append([]SHA(nil), parents...)
+	return commit, nil
+}
+
+// ParentsOf returns the parents of the commit with the given name.
+// Replace refs and grafts are taken into account; if the commit
+// has been replaced, the grafts for it are ignored.
+func (r *Repository) ParentsOf(name SHA) ([]SHA, error) {
+	commit, err := r.commit(name)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Parents, nil
+}
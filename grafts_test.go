@@ -0,0 +1,96 @@
+package gitgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ReadGrafts(t *testing.T) {
+	input := "# grafted history\n" +
+		"37213e7bb3c334a0f7708c7afcab5babb3f95434 fe89ee30bbcdfdf376beae530cc53f967012f31c 3ead3116d0378089f5ce61086354aac43e736b01\n" +
+		"\n" +
+		"4bab381d0209b95160f8cc8761fe479ad72187d8\n"
+	expected := map[SHA][]SHA{
+		"37213e7bb3c334a0f7708c7afcab5babb3f95434": {"fe89ee30bbcdfdf376beae530cc53f967012f31c", "3ead3116d0378089f5ce61086354aac43e736b01"},
+		"4bab381d0209b95160f8cc8761fe479ad72187d8": {},
+	}
+	result, err := ReadGrafts(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, result)
+	}
+
+	if _, err := ReadGrafts(strings.NewReader("37213e7 fe89ee3\n")); err == nil {
+		t.Errorf("expected error for abbreviated names")
+	}
+}
+
+func Test_Grafts(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+	dir := repo.Basedir.Name()
+
+	const head = SHA("37213e7bb3c334a0f7708c7afcab5babb3f95434")
+	grafts := string(head) + " fe89ee30bbcdfdf376beae530cc53f967012f31c\n" +
+		"3ead3116d0378089f5ce61086354aac43e736b01\n"
+	if err := os.MkdirAll(filepath.Join(dir, "info"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "info", "grafts"), []byte(grafts), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	parents, err := repo.ParentsOf(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]SHA{"fe89ee30bbcdfdf376beae530cc53f967012f31c"}, parents) {
+		t.Errorf("expected the grafted parent and received %v", parents)
+	}
+
+	// the graft also applies when the commit is read by an abbreviated name
+	obj, err := repo.Object("37213e7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit, ok := obj.(Commit); !ok || !reflect.DeepEqual([]SHA{"fe89ee30bbcdfdf376beae530cc53f967012f31c"}, commit.Parents) {
+		t.Errorf("expected the grafted parent for an abbreviated name and received %+v", obj)
+	}
+
+	// history now skips from head to fe89ee3, and stops at 3ead311
+	commits, err := repo.Log(head, LogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []SHA
+	for _, commit := range commits {
+		names = append(names, commit.Name)
+	}
+	expected := []SHA{head, "fe89ee30bbcdfdf376beae530cc53f967012f31c", "3ead3116d0378089f5ce61086354aac43e736b01"}
+	if !reflect.DeepEqual(expected, names) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, names)
+	}
+
+	// a replace ref takes precedence over the graft
+	replacement := writeTestCommit(t, repo, "0c8257b5f5348dc6cfd29e5e519d058535d5678f", 1428611978, "replacement", "b499a18d0aea475863ef88dcdb0941ca71a53b13")
+	if err := os.MkdirAll(filepath.Join(dir, "refs", "replace"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "refs", "replace", string(head)), []byte(string(replacement)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	repo = &Repository{Basedir: repo.Basedir}
+	parents, err = repo.ParentsOf(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]SHA{"b499a18d0aea475863ef88dcdb0941ca71a53b13"}, parents) {
+		t.Errorf("expected the replacement's parent and received %v", parents)
+	}
+}
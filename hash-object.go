@@ -0,0 +1,97 @@
+package gitgo
+
+import (
+	"bufio"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteLooseObjectStream writes an object to the object store in basedir
+// (the .git directory) and returns its name. The contents are streamed from r,
+// which must provide exactly size bytes. To detect extra data, one byte past
+// size is read from r (if it has one), and is not put back. Because the
+// contents are hashed and compressed as they are read, memory usage does not
+// depend on the size of the object, which makes this suitable for importing
+// large blobs.
+func WriteLooseObjectStream(basedir string, objType packObjectType, size int64, r io.Reader) (SHA, error) {
+	var typeName string
+	switch objType {
+	case OBJ_COMMIT:
+		typeName = "commit"
+	case OBJ_TREE:
+		typeName = "tree"
+	case OBJ_BLOB:
+		typeName = "blob"
+	case OBJ_TAG:
+		typeName = "tag"
+	default:
+		return "", fmt.Errorf("cannot write object of type %s", objType)
+	}
+
+	objectsDir := filepath.Join(basedir, "objects")
+	tmp, err := ioutil.TempFile(objectsDir, "tmp_obj_")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		// if the object was written successfully, the temp file
+		// has already been renamed and this is a no-op
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	bw := bufio.NewWriter(tmp)
+	zw := zlib.NewWriter(bw)
+	h := sha1.New()
+	w := io.MultiWriter(zw, h)
+
+	if _, err := fmt.Fprintf(w, "%s %d\x00", typeName, size); err != nil {
+		return "", err
+	}
+	// reading one extra byte detects trailing data; the object
+	// is discarded in that case, so writing it to w does no harm
+	n, err := io.Copy(w, io.LimitReader(r, size+1))
+	if err != nil {
+		return "", err
+	}
+	if n > size {
+		return "", fmt.Errorf("expected %d bytes and read more", size)
+	}
+	if n != size {
+		return "", fmt.Errorf("expected %d bytes and read %d", size, n)
+	}
+
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	if err := bw.Flush(); err != nil {
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	name := SHA(hex.EncodeToString(h.Sum(nil)))
+	dir := filepath.Join(objectsDir, string(name[:2]))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	filename := filepath.Join(dir, string(name[2:]))
+	if _, err := os.Stat(filename); err == nil {
+		// the object already exists
+		return name, nil
+	}
+	if err := os.Chmod(tmp.Name(), 0444); err != nil {
+		return "", err
+	}
+	return name, os.Rename(tmp.Name(), filename)
+}
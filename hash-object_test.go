@@ -0,0 +1,70 @@
+package gitgo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_WriteLooseObjectStream(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+	dir := repo.Basedir.Name()
+
+	const contents = "hello\n"
+	const expected = SHA("ce013625030ba8dba906f756967f9e9ca394464a")
+	name, err := WriteLooseObjectStream(dir, OBJ_BLOB, int64(len(contents)), strings.NewReader(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != expected {
+		t.Errorf("expected %s and received %s", expected, name)
+	}
+
+	obj, err := repo.Object(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, ok := obj.(Blob)
+	if !ok {
+		t.Fatalf("expected blob and received %s", obj.Type())
+	}
+	if !bytes.Equal(blob.Contents, []byte(contents)) {
+		t.Errorf("expected %q and received %q", contents, blob.Contents)
+	}
+
+	// writing the same object twice is a no-op
+	name, err = WriteLooseObjectStream(dir, OBJ_BLOB, int64(len(contents)), strings.NewReader(contents))
+	if err != nil || name != expected {
+		t.Errorf("error rewriting object %s: %v", name, err)
+	}
+
+	for _, size := range []int64{int64(len(contents)) - 1, int64(len(contents)) + 1} {
+		_, err = WriteLooseObjectStream(dir, OBJ_BLOB, size, strings.NewReader(contents))
+		if err == nil {
+			t.Errorf("expected error for incorrect size %d", size)
+		}
+	}
+
+	// trailing data is detected by reading a single byte past the object
+	r := strings.NewReader(contents + "extra")
+	if _, err = WriteLooseObjectStream(dir, OBJ_BLOB, int64(len(contents)), r); err == nil {
+		t.Errorf("expected error for trailing data")
+	}
+	if r.Len() != len("extra")-1 {
+		t.Errorf("expected %d unread bytes and received %d", len("extra")-1, r.Len())
+	}
+
+	// failed writes should not leave temporary files behind
+	files, err := ioutil.ReadDir(filepath.Join(dir, "objects"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), "tmp_obj_") {
+			t.Errorf("temporary file was not removed: %s", file.Name())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package gitgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A Hook is a script that git runs at a particular point,
+// such as "pre-commit"
+type Hook struct {
+	Name string
+	Path string
+
+	// Enabled is true if the script is executable.
+	// git ignores hooks that are not executable.
+	Enabled bool
+}
+
+// Hooks lists the hooks that are installed in the repository, sorted by
+// name. They are read from .git/hooks, or from core.hooksPath if it is set.
+// The sample hooks (named "<hook>.sample") that git installs by default are
+// not included, since git never runs them. If the hooks directory does not
+// exist, it returns no hooks and no error.
+func Hooks(repo *Repository) ([]Hook, error) {
+	dir, err := hooksDir(repo)
+	if err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var hooks []Hook
+	for _, file := range files {
+		if file.IsDir() || strings.HasSuffix(file.Name(), ".sample") {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+
+		// hooks may be symlinks to scripts stored elsewhere
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		hooks = append(hooks, Hook{
+			Name:    file.Name(),
+			Path:    path,
+			Enabled: info.Mode()&0111 != 0,
+		})
+	}
+	return hooks, nil
+}
+
+// hooksDir returns the directory containing the repository's hooks.
+// By default, this is the hooks directory that is shared by all worktrees.
+// A relative core.hooksPath is relative to the root of the working tree,
+// or to the git directory for a bare repository.
+func hooksDir(repo *Repository) (string, error) {
+	dir, err := repo.gitDir()
+	if err != nil {
+		return "", err
+	}
+	common, err := commonDir(dir)
+	if err != nil {
+		return "", err
+	}
+	config, err := repo.Config()
+	if err != nil {
+		return "", err
+	}
+	hooksPath, ok := config.Get("core.hooksPath")
+	if !ok || hooksPath == "" {
+		return filepath.Join(common, "hooks"), nil
+	}
+
+	if strings.HasPrefix(hooksPath, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, hooksPath[2:]), nil
+	}
+	if filepath.IsAbs(hooksPath) {
+		return hooksPath, nil
+	}
+
+	bare, err := config.Bool("core.bare")
+	if err != nil {
+		return "", err
+	}
+	// a linked worktree of a bare repository still has a working tree
+	if bare && common == dir {
+		return filepath.Join(dir, hooksPath), nil
+	}
+	root, err := worktreeRoot(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, hooksPath), nil
+}
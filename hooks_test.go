@@ -0,0 +1,105 @@
+package gitgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_Hooks(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+	dir := repo.Basedir.Name()
+
+	// the test repository only has sample hooks
+	hooks, err := Hooks(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 0 {
+		t.Errorf("expected no hooks and received %+v", hooks)
+	}
+
+	hooksDir := filepath.Join(dir, "hooks")
+	if err := ioutil.WriteFile(filepath.Join(hooksDir, "pre-commit"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(hooksDir, "commit-msg"), []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Hook{
+		{Name: "commit-msg", Path: filepath.Join(hooksDir, "commit-msg"), Enabled: false},
+		{Name: "pre-commit", Path: filepath.Join(hooksDir, "pre-commit"), Enabled: true},
+	}
+	hooks, err = Hooks(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, hooks) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, hooks)
+	}
+
+	// linked worktrees share the hooks of the main repository
+	worktreeGitdir, worktreeRoot := addTestWorktree(t, repo, "linked")
+	f, err := os.Open(worktreeGitdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked := &Repository{Basedir: *f}
+	hooks, err = Hooks(linked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, hooks) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, hooks)
+	}
+
+	// core.hooksPath is relative to the working tree
+	customDir := filepath.Join(filepath.Dir(dir), "githooks")
+	if err := os.Mkdir(customDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(customDir, "pre-push"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err = os.OpenFile(filepath.Join(dir, "config"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("[core]\n\thooksPath = githooks\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	expected = []Hook{
+		{Name: "pre-push", Path: filepath.Join(customDir, "pre-push"), Enabled: true},
+	}
+	hooks, err = Hooks(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, hooks) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, hooks)
+	}
+
+	// in a linked worktree, it is relative to that worktree
+	linkedDir := filepath.Join(worktreeRoot, "githooks")
+	if err := os.Mkdir(linkedDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(linkedDir, "post-checkout"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	expected = []Hook{
+		{Name: "post-checkout", Path: filepath.Join(linkedDir, "post-checkout"), Enabled: true},
+	}
+	hooks, err = Hooks(linked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, hooks) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, hooks)
+	}
+}
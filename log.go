@@ -1,12 +1,17 @@
 package gitgo
 
 import (
+	"container/heap"
+	"errors"
 	"fmt"
 	"os"
-	"reflect"
 )
 
-// Log is equivalent to `git log <SHA>`. If basedir is non-nil
+// ErrPathNotFound is returned when a path does not appear
+// in any of the commits that were searched
+var ErrPathNotFound = errors.New("path not found in history")
+
+// Log is equivalent to `git log --first-parent <SHA>`. If basedir is non-nil
 // and points to a valid git respository, the command will be run
 // using that repository.
 func Log(name SHA, basedir *os.File) ([]Commit, error) {
@@ -17,63 +22,319 @@ func Log(name SHA, basedir *os.File) ([]Commit, error) {
 	defer dir.Close()
 
 	repo := Repository{Basedir: *dir}
-	as, err := repo.allAncestors(name)
-	if err != nil {
-		return nil, err
+	return repo.Log(name, LogOptions{FirstParent: true})
+}
+
+// LogOptions control which commits are returned by Log,
+// and in which order
+type LogOptions struct {
+	// FirstParent follows only the first parent of merge commits,
+	// which shows the history of a branch as it was merged
+	// (as in git log --first-parent)
+	FirstParent bool
+
+	// TopoOrder guarantees that no commit is shown before all of its
+	// children, and avoids interleaving commits from multiple lines
+	// of history (as in git log --topo-order). By default, commits
+	// are shown in reverse chronological order by commit date.
+	TopoOrder bool
+}
+
+// Log returns the commit with the given name, followed by its ancestors
+func (r *Repository) Log(name SHA, opts LogOptions) ([]Commit, error) {
+	if opts.TopoOrder {
+		return r.topoOrderLog(name, opts)
 	}
 
-	obj, err := repo.Object(name)
+	start, err := r.commit(name)
 	if err != nil {
 		return nil, fmt.Errorf("commit not found: %s", err)
 	}
-	result := make([]Commit, len(as)+1)
-	result[0] = obj.(Commit)
-	for i, o := range as {
-		result[i+1] = o
+
+	var result []Commit
+	seen := map[SHA]bool{start.Name: true}
+	queue := &commitQueue{}
+	heap.Push(queue, start)
+	for queue.Len() > 0 {
+		commit := heap.Pop(queue).(Commit)
+		result = append(result, commit)
+
+		for _, parentName := range logParents(commit, opts) {
+			if seen[parentName] {
+				continue
+			}
+			seen[parentName] = true
+			parent, err := r.commit(parentName)
+			if err != nil {
+				return result, err
+			}
+			heap.Push(queue, parent)
+		}
 	}
 	return result, nil
 }
 
-func (r *Repository) allAncestors(name SHA) ([]Commit, error) {
-	basedir := r.Basedir
-	obj, err := r.Object(name)
+// topoOrderLog uses Kahn's algorithm to sort the commits reachable
+// from name, so that every commit appears before its parents
+func (r *Repository) topoOrderLog(name SHA, opts LogOptions) ([]Commit, error) {
+	start, err := r.commit(name)
 	if err != nil {
 		return nil, fmt.Errorf("commit not found: %s", err)
 	}
 
-	var commit Commit
-	switch obj := obj.(type) {
-	case *packObject:
-		// TODO check that this case is logically possible
-		commit, err = obj.Commit(basedir)
+	// First, load every reachable commit and count its children
+	commits := map[SHA]Commit{start.Name: start}
+	children := map[SHA]int{}
+	pending := []Commit{start}
+	for len(pending) > 0 {
+		commit := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, parentName := range logParents(commit, opts) {
+			children[parentName]++
+			if _, ok := commits[parentName]; ok {
+				continue
+			}
+			parent, err := r.commit(parentName)
+			if err != nil {
+				return nil, err
+			}
+			commits[parentName] = parent
+			pending = append(pending, parent)
+		}
+	}
+
+	// Then emit each commit once all of its children have been emitted.
+	// Using a stack means that we follow one line of history
+	// as far as possible before switching to another
+	result := make([]Commit, 0, len(commits))
+	ready := []Commit{start}
+	for len(ready) > 0 {
+		commit := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		result = append(result, commit)
+
+		// push the parents in reverse order so that
+		// the first parent is visited first
+		parents := logParents(commit, opts)
+		for i := len(parents) - 1; i >= 0; i-- {
+			children[parents[i]]--
+			if children[parents[i]] == 0 {
+				ready = append(ready, commits[parents[i]])
+			}
+		}
+	}
+	return result, nil
+}
+
+// FileDeletedAt returns the most recent commit in the history of start
+// that deleted the file (or directory) at path: the path exists in one
+// of the commit's parents, but not in the commit itself.
+// If the path does not exist in any commit, it returns ErrPathNotFound.
+func FileDeletedAt(repo *Repository, path string, start SHA) (SHA, error) {
+	commits, err := repo.Log(start, LogOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	exists := map[SHA]bool{}
+	pathExists := func(commit SHA) (bool, error) {
+		if ok, seen := exists[commit]; seen {
+			return ok, nil
+		}
+		tree, err := repo.treeOf(commit)
 		if err != nil {
-			return nil, err
+			return false, err
 		}
-	case Commit:
-		commit = obj
-	default:
-		return nil, fmt.Errorf("not a commit")
+		_, ok, err := repo.lookupPath(tree, path)
+		exists[commit] = ok
+		return ok, err
 	}
 
-	parents := []Commit{}
-	if len(commit.Parents) > 0 {
-		// By default, git-log uses the first parent in merges
-		obj, err := r.Object(commit.Parents[0])
+	var found bool
+	for _, commit := range commits {
+		ok, err := pathExists(commit.Name)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			found = true
+			continue
+		}
+		for _, parent := range commit.Parents {
+			ok, err := pathExists(parent)
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				return commit.Name, nil
+			}
+		}
+	}
+
+	if found {
+		return "", fmt.Errorf("%s was never deleted in the history of %s", path, start)
+	}
+	return "", fmt.Errorf("%w: %s", ErrPathNotFound, path)
+}
+
+// LastModified returns the most recent commit in the history of start that
+// modified each of the given paths, which are relative to tree (the root
+// tree of start). This is the commit shown next to each file when browsing
+// a directory.
+//
+// A commit modifies a path if the path's entry differs from its entry in
+// every parent. Only a commit that leaves the path as it is in start is
+// attributed, so a change on a side branch that a merge discarded is not
+// reported. History is walked only once, and the walk stops as soon as
+// every path has been attributed to a commit.
+func LastModified(repo *Repository, tree Tree, paths []string, start SHA) (map[string]SHA, error) {
+	// pending maps each path that has not been attributed yet
+	// to its entry in start
+	pending := map[string]*objectMeta{}
+	for _, p := range paths {
+		entry, ok, err := repo.lookupPath(tree, p)
 		if err != nil {
 			return nil, err
 		}
-		parent, ok := obj.(Commit)
 		if !ok {
-			fmt.Println(reflect.TypeOf(obj))
-			return nil, fmt.Errorf("receved non-commit object parent: %s (%s)", commit.Parents[0], obj.Type())
+			return nil, fmt.Errorf("%w: %s", ErrPathNotFound, p)
 		}
+		pending[p] = &entry
+	}
 
-		parents = append(parents, parent)
-		ancestors, err := r.allAncestors(SHA(commit.Parents[0]))
+	// entries caches the entry at each path in each root tree, since
+	// consecutive commits usually share most of their trees.
+	// A nil entry means that the path does not exist.
+	entries := map[SHA]map[string]*objectMeta{}
+	entryAt := func(root SHA, p string) (*objectMeta, error) {
+		if entry, ok := entries[root][p]; ok {
+			return entry, nil
+		}
+		rootTree, err := repo.tree(root)
 		if err != nil {
-			return parents, err
+			return nil, err
 		}
-		parents = append(parents, ancestors...)
+		entry, ok, err := repo.lookupPath(rootTree, p)
+		if err != nil {
+			return nil, err
+		}
+		if entries[root] == nil {
+			entries[root] = map[string]*objectMeta{}
+		}
+		if ok {
+			entries[root][p] = &entry
+		} else {
+			entries[root][p] = nil
+		}
+		return entries[root][p], nil
+	}
+
+	startCommit, err := repo.commit(start)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]SHA{}
+	commits := map[SHA]Commit{start: startCommit}
+	queue := &commitQueue{}
+	heap.Push(queue, startCommit)
+	for queue.Len() > 0 && len(pending) > 0 {
+		commit := heap.Pop(queue).(Commit)
+
+		var parents []Commit
+		for _, name := range commit.Parents {
+			parent, ok := commits[name]
+			if !ok {
+				parent, err = repo.commit(name)
+				if err != nil {
+					return nil, err
+				}
+				commits[name] = parent
+				heap.Push(queue, parent)
+			}
+			parents = append(parents, parent)
+		}
+
+		for p, startEntry := range pending {
+			entry, err := entryAt(SHA(commit.Tree), p)
+			if err != nil {
+				return nil, err
+			}
+			if !sameEntry(entry, startEntry) {
+				continue
+			}
+			modified := true
+			for _, parent := range parents {
+				parentEntry, err := entryAt(SHA(parent.Tree), p)
+				if err != nil {
+					return nil, err
+				}
+				if sameEntry(entry, parentEntry) {
+					modified = false
+					break
+				}
+			}
+			if modified {
+				result[p] = commit.Name
+				delete(pending, p)
+			}
+		}
+	}
+	return result, nil
+}
+
+// sameEntry returns true if a and b refer to the same object with
+// the same mode. nil entries (for paths that do not exist) are equal.
+func sameEntry(a, b *objectMeta) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Hash == b.Hash && a.Perms == b.Perms
+}
+
+// logParents returns the parents of commit that Log should follow
+func logParents(commit Commit, opts LogOptions) []SHA {
+	if opts.FirstParent && len(commit.Parents) > 1 {
+		return commit.Parents[:1]
 	}
-	return parents, nil
+	return commit.Parents
+}
+
+// commit returns the commit with the given name
+func (r *Repository) commit(name SHA) (Commit, error) {
+	obj, err := r.Object(name)
+	if err != nil {
+		return Commit{}, err
+	}
+
+	switch obj := obj.(type) {
+	case *packObject:
+		// TODO check that this case is logically possible
+		return obj.Commit(r.Basedir)
+	case Commit:
+		return obj, nil
+	}
+	return Commit{}, unexpectedType(name, obj, "commit")
+}
+
+// commitQueue is a priority queue of commits,
+// ordered from newest to oldest by commit date
+type commitQueue []Commit
+
+func (q commitQueue) Len() int { return len(q) }
+
+func (q commitQueue) Less(i, j int) bool {
+	return q[i].CommitterDate.After(q[j].CommitterDate)
+}
+
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *commitQueue) Push(x interface{}) {
+	*q = append(*q, x.(Commit))
+}
+
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
 }
@@ -1,7 +1,9 @@
 package gitgo
 
 import (
+	"errors"
 	"log"
+	"reflect"
 	"testing"
 )
 
@@ -38,3 +40,159 @@ func Test_SlowLog(t *testing.T) {
 		t.Skip("Failed to read %s: %s", input, err)
 	}
 }
+
+func Test_LogOrder(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	// root -- b -- b2 -- merge
+	//     \              /
+	//      `---- c -----'
+	// b2 has a skewed clock, so its commit date is older than its parent
+	tree := writeTestTree(t, repo)
+	root := writeTestCommit(t, repo, tree, 100, "root")
+	b := writeTestCommit(t, repo, tree, 200, "b", root)
+	c := writeTestCommit(t, repo, tree, 300, "c", root)
+	b2 := writeTestCommit(t, repo, tree, 50, "b2", b)
+	merge := writeTestCommit(t, repo, tree, 500, "merge", b2, c)
+
+	cases := []struct {
+		description string
+		opts        LogOptions
+		expected    []SHA
+	}{
+		{"date order", LogOptions{}, []SHA{merge, c, root, b2, b}},
+		{"topo order", LogOptions{TopoOrder: true}, []SHA{merge, b2, b, c, root}},
+		{"first parent", LogOptions{FirstParent: true}, []SHA{merge, b2, b, root}},
+		{"first parent topo order", LogOptions{FirstParent: true, TopoOrder: true}, []SHA{merge, b2, b, root}},
+	}
+	for _, c := range cases {
+		commits, err := repo.Log(merge, c.opts)
+		if err != nil {
+			t.Errorf("%s: %s", c.description, err)
+			continue
+		}
+		result := make([]SHA, len(commits))
+		for i, commit := range commits {
+			result[i] = commit.Name
+		}
+		if !reflect.DeepEqual(c.expected, result) {
+			t.Errorf("%s: expected %v and received %v", c.description, c.expected, result)
+		}
+	}
+
+	commit, err := repo.commit(merge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(commit.Parents, []SHA{b2, c}) {
+		t.Errorf("expected parents %v and received %v", []SHA{b2, c}, commit.Parents)
+	}
+}
+
+func Test_FileDeletedAt(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	gone := writeTestObject(t, repo, OBJ_BLOB, []byte("soon to be deleted\n"))
+	keep := writeTestObject(t, repo, OBJ_BLOB, []byte("keep\n"))
+	dir := writeTestTree(t, repo, testTreeEntry{"100644", "gone.txt", gone})
+	before := writeTestTree(t, repo,
+		testTreeEntry{"40000", "a", dir},
+		testTreeEntry{"100644", "keep", keep},
+	)
+	after := writeTestTree(t, repo, testTreeEntry{"100644", "keep", keep})
+
+	added := writeTestCommit(t, repo, before, 1500000000, "Add a file", "37213e7bb3c334a0f7708c7afcab5babb3f95434")
+	deleted := writeTestCommit(t, repo, after, 1500000100, "Delete a file", added)
+	head := writeTestCommit(t, repo, after, 1500000200, "Another commit", deleted)
+
+	for _, path := range []string{"a/gone.txt", "a", "/a/gone.txt"} {
+		result, err := FileDeletedAt(repo, path, head)
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			continue
+		}
+		if result != deleted {
+			t.Errorf("%s: expected %s and received %s", path, deleted, result)
+		}
+	}
+
+	if _, err := FileDeletedAt(repo, "a/never.txt", head); !errors.Is(err, ErrPathNotFound) {
+		t.Errorf("expected ErrPathNotFound and received %v", err)
+	}
+	if _, err := FileDeletedAt(repo, "keep", head); err == nil || errors.Is(err, ErrPathNotFound) {
+		t.Errorf("expected error for a file that was never deleted and received %v", err)
+	}
+}
+
+func Test_LastModified(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	a1 := writeTestObject(t, repo, OBJ_BLOB, []byte("a\n"))
+	a2 := writeTestObject(t, repo, OBJ_BLOB, []byte("a, modified\n"))
+	b := writeTestObject(t, repo, OBJ_BLOB, []byte("b\n"))
+	c := writeTestObject(t, repo, OBJ_BLOB, []byte("c\n"))
+	dir1 := writeTestTree(t, repo, testTreeEntry{"100644", "b", b})
+	dir2 := writeTestTree(t, repo,
+		testTreeEntry{"100644", "b", b},
+		testTreeEntry{"100644", "c", c},
+	)
+
+	first := writeTestCommit(t, repo, writeTestTree(t, repo,
+		testTreeEntry{"100644", "a", a1},
+		testTreeEntry{"40000", "dir", dir1},
+	), 1500000000, "Add a and b", "37213e7bb3c334a0f7708c7afcab5babb3f95434")
+	second := writeTestCommit(t, repo, writeTestTree(t, repo,
+		testTreeEntry{"100644", "a", a2},
+		testTreeEntry{"40000", "dir", dir1},
+	), 1500000100, "Modify a", first)
+	root := writeTestTree(t, repo,
+		testTreeEntry{"100644", "a", a2},
+		testTreeEntry{"40000", "dir", dir2},
+	)
+	third := writeTestCommit(t, repo, root, 1500000200, "Add c", second)
+
+	tree, err := repo.tree(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]SHA{
+		"a":     second,
+		"dir":   third,
+		"dir/b": first,
+		"dir/c": third,
+	}
+	result, err := LastModified(repo, tree, []string{"a", "dir", "dir/b", "dir/c"}, third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, result)
+	}
+
+	if _, err := LastModified(repo, tree, []string{"missing"}, third); !errors.Is(err, ErrPathNotFound) {
+		t.Errorf("expected ErrPathNotFound and received %v", err)
+	}
+
+	// a more recent change to a on a side branch, which the merge discards
+	a3 := writeTestObject(t, repo, OBJ_BLOB, []byte("a, modified on a branch\n"))
+	side := writeTestCommit(t, repo, writeTestTree(t, repo,
+		testTreeEntry{"100644", "a", a3},
+		testTreeEntry{"40000", "dir", dir1},
+	), 1500000300, "Modify a on a branch", first)
+	merge := writeTestCommit(t, repo, root, 1500000400, "Merge branch", third, side)
+
+	expected = map[string]SHA{
+		"a":     second,
+		"dir/c": third,
+	}
+	result, err = LastModified(repo, tree, []string{"a", "dir/c"}, merge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, result)
+	}
+}
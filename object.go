@@ -3,7 +3,10 @@ package gitgo
 import (
 	"bufio"
 	"bytes"
+	"compress/flate"
 	"compress/zlib"
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -68,8 +71,21 @@ type Commit struct {
 	Committer     string
 	CommitterDate time.Time
 	Message       []byte
-	size          string
-	rawData       []byte
+
+	// ExtraHeaders holds the headers that are not parsed into the fields
+	// above (such as gpgsig, mergetag, and encoding), in order
+	ExtraHeaders []RawHeader
+
+	size    string
+	rawData []byte
+}
+
+// A RawHeader is a commit header that is preserved as-is.
+// The lines of a multi-line value are joined by newlines,
+// without the space that begins each continuation line.
+type RawHeader struct {
+	Key   string
+	Value string
 }
 
 func (c Commit) Type() string {
@@ -80,13 +96,35 @@ type Tree struct {
 	_type string
 	Blobs []objectMeta
 	Trees []objectMeta
-	size  string
+
+	// Gitlinks are submodule entries, which refer to
+	// commits in another repository
+	Gitlinks []objectMeta
+	size     string
 }
 
 func (t Tree) Type() string {
 	return t._type
 }
 
+// A Tag is an annotated tag, which points to another object
+// (usually a commit) and carries its own author and message
+type Tag struct {
+	_type      string
+	Name       SHA
+	Object     SHA
+	ObjectType string
+	Tag        string
+	Tagger     string
+	TaggerDate time.Time
+	Message    []byte
+	size       string
+}
+
+func (t Tag) Type() string {
+	return t._type
+}
+
 // objectMeta contains the metadata
 // (hash, permissions, and filename)
 // corresponding either to a blob (leaf) or another tree
@@ -103,7 +141,7 @@ func NewObject(input SHA, basedir os.File) (obj GitObject, err error) {
 
 func newObject(input SHA, basedir *os.File, packfiles []*packfile) (obj GitObject, err error) {
 
-	if filepath.Base(basedir.Name()) != ".git" {
+	if filepath.Base(basedir.Name()) != ".git" && !isGitDir(basedir.Name()) {
 		defer basedir.Close()
 		basedir, err = os.Open(filepath.Join(basedir.Name(), ".git"))
 		if err != nil {
@@ -176,30 +214,99 @@ func newObject(input SHA, basedir *os.File, packfiles []*packfile) (obj GitObjec
 		return nil, fmt.Errorf("object not in any packfile: %s", input)
 	}
 
+	return objectFromFile(filename, input, *basedir)
+}
+
+func objectFromFile(filename string, name SHA, basedir os.File) (GitObject, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-	r, err := zlib.NewReader(f)
+	r, err := inflateLooseObject(f)
 	if err != nil {
 		return nil, err
 	}
-	return parseObj(r, input, *basedir)
-
+	return parseObj(r, name, basedir)
 }
 
-func objectFromFile(filename string, name SHA, basedir os.File) (GitObject, error) {
-	f, err := os.Open(filename)
+// inflateLooseObject returns a reader for the decompressed contents
+// of a loose object file. Loose objects are zlib streams, but some
+// non-canonical tools have written them as raw deflate streams
+// (without the zlib header), so those are accepted as well.
+//
+// A raw deflate stream can begin with two bytes that happen to form a
+// valid zlib header, in which case the problem only shows up later,
+// as corrupt data or a bad checksum. So the object is inflated in full
+// before it is returned, and is retried as raw deflate if that fails.
+func inflateLooseObject(f io.Reader) (io.Reader, error) {
+	compressed, err := ioutil.ReadAll(f)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	r, err := zlib.NewReader(f)
+	data, err := inflateZlib(compressed)
+	if err == nil {
+		return bytes.NewReader(data), nil
+	}
+	if _, corrupt := err.(flate.CorruptInputError); !corrupt &&
+		err != zlib.ErrHeader && err != zlib.ErrChecksum && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	data, rawErr := ioutil.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	if rawErr != nil {
+		// it is not raw deflate either, so report the original error
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// inflateZlib returns the decompressed contents of a zlib stream
+func inflateZlib(compressed []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
 	if err != nil {
 		return nil, err
 	}
-	return parseObj(r, name, basedir)
+	return ioutil.ReadAll(zr)
+}
+
+// looseObjectSize returns the size recorded in the header of a loose
+// object file. Only the header is inflated, unless the file is not a
+// valid zlib stream (see inflateLooseObject).
+func looseObjectSize(f io.ReadSeeker) (int, error) {
+	if zr, err := zlib.NewReader(f); err == nil {
+		if _, size, err := readLooseHeader(zr); err == nil {
+			if n, err := strconv.Atoi(size); err == nil {
+				return n, nil
+			}
+		}
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	r, err := inflateLooseObject(f)
+	if err != nil {
+		return 0, err
+	}
+	_, size, err := readLooseHeader(r)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(size)
+}
+
+// hashObject returns the name of an object with the given
+// type and contents, which is the hash of the object header
+// followed by the contents. hashSize is the width of the name
+// in bytes: 20 for SHA-1, or 32 for SHA-256.
+func hashObject(objType string, data []byte, hashSize int) SHA {
+	h := sha1.New()
+	if hashSize == sha256.Size {
+		h = sha256.New()
+	}
+	fmt.Fprintf(h, "%s %d\x00", objType, len(data))
+	h.Write(data)
+	return SHA(hex.EncodeToString(h.Sum(nil)))
 }
 
 func normalizePerms(perms string) string {
@@ -211,16 +318,37 @@ func normalizePerms(perms string) string {
 }
 
 func parseObj(r io.Reader, name SHA, basedir os.File) (result GitObject, err error) {
+	resultType, resultSize, err := readLooseHeader(r)
+	if err != nil {
+		return nil, err
+	}
 
-	var resultType string
-	var resultSize string
+	switch resultType {
+	case "commit":
+		return parseCommit(r, resultSize, name)
+	case "tree":
+		return parseTree(r, resultSize)
+	case "blob":
+		return parseBlob(r, resultSize)
+	case "tag":
+		return parseTag(r, resultSize, name)
+	default:
+		err = fmt.Errorf("Received unknown object type %s", resultType)
+	}
+
+	return
+}
+
+// readLooseHeader reads the header of a (decompressed) loose object,
+// "<type> <size>\x00", leaving r positioned at the start of the contents
+func readLooseHeader(r io.Reader) (objType string, size string, err error) {
 	scnr := scanner{r, nil, nil}
 	for scnr.scan() {
 		txt := string(scnr.data)
 		if txt == " " {
 			break
 		}
-		resultType += txt
+		objType += txt
 	}
 
 	for scnr.scan() {
@@ -228,25 +356,9 @@ func parseObj(r io.Reader, name SHA, basedir os.File) (result GitObject, err err
 		if txt == "\x00" {
 			break
 		}
-		resultSize += txt
-	}
-
-	if scnr.Err() != nil {
-		return nil, scnr.Err()
-	}
-
-	switch resultType {
-	case "commit":
-		return parseCommit(r, resultSize, name)
-	case "tree":
-		return parseTree(r, resultSize, basedir)
-	case "blob":
-		return parseBlob(r, resultSize)
-	default:
-		err = fmt.Errorf("Received unknown object type %s", resultType)
+		size += txt
 	}
-
-	return
+	return objType, size, scnr.Err()
 }
 
 func parseCommit(r io.Reader, resultSize string, name SHA) (Commit, error) {
@@ -259,6 +371,15 @@ func parseCommit(r io.Reader, resultSize string, name SHA) (Commit, error) {
 	for scnr.Scan() {
 		line := scnr.Bytes()
 		trimmedLine := bytes.TrimRight(line, "\r\n")
+
+		// a line beginning with a space continues the value
+		// of the previous (extra) header, even if it is otherwise blank
+		if commitMessageLines == nil && len(trimmedLine) > 0 && trimmedLine[0] == ' ' && len(commit.ExtraHeaders) > 0 {
+			last := &commit.ExtraHeaders[len(commit.ExtraHeaders)-1]
+			last.Value += "\n" + string(trimmedLine[1:])
+			continue
+		}
+
 		if commitMessageLines == nil && len(bytes.Fields(trimmedLine)) == 0 {
 			// Everything after the first empty line is the commit message
 			commitMessageLines = [][]byte{}
@@ -295,8 +416,9 @@ func parseCommit(r io.Reader, resultSize string, name SHA) (Commit, error) {
 			commit.Committer = committer
 			commit.CommitterDate = date
 		default:
-			err := fmt.Errorf("encountered unknown field in commit: %s", key)
-			return commit, err
+			// keep the value exactly as written, apart from the trailing CR
+			value := bytes.TrimPrefix(trimmedLine[len(key):], []byte(" "))
+			commit.ExtraHeaders = append(commit.ExtraHeaders, RawHeader{string(key), string(value)})
 		}
 	}
 	commit.Name = name
@@ -304,81 +426,97 @@ func parseCommit(r io.Reader, resultSize string, name SHA) (Commit, error) {
 	return commit, nil
 }
 
-func parseTree(r io.Reader, resultSize string, basedir os.File) (Tree, error) {
-	var tree = Tree{_type: "tree", size: resultSize}
+func parseTag(r io.Reader, resultSize string, name SHA) (Tag, error) {
+	var tag = Tag{_type: "tag", Name: name, size: resultSize}
 
-	scanner := bufio.NewScanner(r)
-	scanner.Split(ScanNullLines)
-
-	var tmp objectMeta
-
-	var resultObjs []objectMeta
+	scnr := bufio.NewScanner(r)
+	scnr.Split(ScanLinesNoTrim)
 
-	for count := 0; ; count++ {
-		done := !scanner.Scan()
-		if done {
-			break
+	var messageLines [][]byte
+	for scnr.Scan() {
+		line := scnr.Bytes()
+		trimmedLine := bytes.TrimRight(line, "\r\n")
+		if messageLines == nil && len(bytes.Fields(trimmedLine)) == 0 {
+			// Everything after the first empty line is the tag message
+			messageLines = [][]byte{}
+			continue
 		}
 
-		txt := scanner.Text()
-
-		if count == 0 {
-			// the first time through, scanner.Text() will be
-			// <perms> <filename>
-			// separated by a space
-			fields := strings.Fields(txt)
-			tmp.Perms = normalizePerms(fields[0])
-			tmp.filename = fields[1]
+		if messageLines != nil {
+			messageLines = append(messageLines, line)
 			continue
 		}
 
-		// after the first time through, scanner.Text() will be
-		// <sha><perms2> <file2>
-		// where perms2 and file2 refer to the permissions and filename (respectively)
-		// of the NEXT object, and <sha> is the first 20 bytes exactly.
-		// If there is no next object (this is the last object)
-		// then scanner.Text() will yield exactly 20 bytes.
-
-		// decode the next 20 bytes to get the SHA
-		tmp.Hash = SHA(hex.EncodeToString([]byte(txt[:20])))
-		resultObjs = append(resultObjs, tmp)
-		if len(txt) <= 20 {
-			// We've read the last line
-			break
+		parts := bytes.Fields(trimmedLine)
+		switch string(parts[0]) {
+		case "object":
+			tag.Object = SHA(parts[1])
+		case "type":
+			tag.ObjectType = string(parts[1])
+		case "tag":
+			tag.Tag = string(bytes.Join(parts[1:], []byte(" ")))
+		case "tagger":
+			taggerline := string(bytes.Join(parts[1:], []byte(" ")))
+			tagger, date, err := parseAuthorString(taggerline)
+			if err != nil {
+				return tag, err
+			}
+			tag.Tagger = tagger
+			tag.TaggerDate = date
+		default:
+			return tag, fmt.Errorf("encountered unknown field in tag: %s", parts[0])
 		}
-
-		// Now, tmp points to the next object in the tree listing
-		tmp = objectMeta{}
-		remainder := txt[20:]
-		fields := strings.Fields(remainder)
-		tmp.Perms = normalizePerms(fields[0])
-		tmp.filename = fields[1]
 	}
-
-	if err := scanner.Err(); err != nil && err != io.EOF {
-		return tree, err
+	if err := scnr.Err(); err != nil {
+		return tag, err
 	}
+	tag.Message = bytes.Join(messageLines, nil)
+	return tag, nil
+}
 
-	for _, part := range resultObjs {
-		obj, err := NewObject(part.Hash, basedir)
+func parseTree(r io.Reader, resultSize string) (Tree, error) {
+	var tree = Tree{_type: "tree", size: resultSize}
+
+	// Each entry is
+	// <perms> <filename>\x00<sha>
+	// where <sha> is exactly 20 bytes, which may themselves
+	// include null characters
+	br := bufio.NewReader(r)
+	var resultObjs []objectMeta
+	for {
+		header, err := br.ReadString(0)
+		if err == io.EOF && header == "" {
+			break
+		}
 		if err != nil {
-			return tree, err
+			return tree, fmt.Errorf("malformed tree entry: %s", err)
+		}
+		fields := strings.SplitN(strings.TrimSuffix(header, "\x00"), " ", 2)
+		if len(fields) != 2 {
+			return tree, fmt.Errorf("malformed tree entry: %q", header)
 		}
 
-		if o, ok := obj.(*packObject); ok {
-			obj, err = o.normalize(basedir)
-			if err != nil {
-				return tree, err
-			}
+		var hash [20]byte
+		if _, err := io.ReadFull(br, hash[:]); err != nil {
+			return tree, fmt.Errorf("malformed tree entry %q: %s", fields[1], err)
 		}
+		resultObjs = append(resultObjs, objectMeta{
+			Hash:     SHA(hex.EncodeToString(hash[:])),
+			Perms:    normalizePerms(fields[0]),
+			filename: fields[1],
+		})
+	}
 
-		switch obj.Type() {
-		case "tree":
+	// the type of each entry is determined by its mode, so that
+	// listing a tree does not require reading all of its entries
+	for _, part := range resultObjs {
+		switch part.Perms {
+		case "040000":
 			tree.Trees = append(tree.Trees, part)
-		case "blob":
-			tree.Blobs = append(tree.Blobs, part)
+		case "160000":
+			tree.Gitlinks = append(tree.Gitlinks, part)
 		default:
-			return tree, fmt.Errorf("Unknown type found: %s", obj.Type())
+			tree.Blobs = append(tree.Blobs, part)
 		}
 	}
 	return tree, nil
@@ -1,6 +1,11 @@
 package gitgo
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -26,3 +31,132 @@ func Test_parseAuthorString(t *testing.T) {
 		t.Errorf("expected date %s and received %s", expectedDate, date)
 	}
 }
+
+func Test_RawDeflateObject(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	// test_data/raw-deflate-object is a loose blob that was
+	// compressed without a zlib header
+	const name = SHA("b9dee1b451cd9f00245d76466cc1e741e299d157")
+	const expected = "written without a zlib header\n"
+	raw, err := ioutil.ReadFile(filepath.Join("test_data", "raw-deflate-object"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(repo.Basedir.Name(), "objects", string(name[:2]), string(name[2:]))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, raw, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := repo.Object(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, ok := obj.(Blob)
+	if !ok {
+		t.Fatalf("expected blob and received %s", obj.Type())
+	}
+	if string(blob.Contents) != expected {
+		t.Errorf("expected %q and received %q", expected, blob.Contents)
+	}
+
+	size, err := repo.ObjectSize(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != len(expected) {
+		t.Errorf("expected size %d and received %d", len(expected), size)
+	}
+}
+
+func Test_RawDeflateObjectZlibHeader(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	// A raw deflate stream made of stored blocks, the first of which holds
+	// a single byte. Its first two bytes (0x78 0x01) are also a valid zlib
+	// header, so the stream is only found not to be zlib while inflating.
+	const expected = "raw deflate with a zlib-like header\n"
+	loose := "blob " + strconv.Itoa(len(expected)) + "\x00" + expected
+	raw := []byte{0x78, 0x01, 0x00, 0xfe, 0xff, loose[0]}
+	rest := loose[1:]
+	raw = append(raw, 0x01, byte(len(rest)), 0x00, ^byte(len(rest)), 0xff)
+	raw = append(raw, rest...)
+
+	name := hashObject("blob", []byte(expected), 20)
+	path := filepath.Join(repo.Basedir.Name(), "objects", string(name[:2]), string(name[2:]))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, raw, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := repo.Object(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, ok := obj.(Blob)
+	if !ok {
+		t.Fatalf("expected blob and received %s", obj.Type())
+	}
+	if string(blob.Contents) != expected {
+		t.Errorf("expected %q and received %q", expected, blob.Contents)
+	}
+
+	size, err := repo.ObjectSize(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != len(expected) {
+		t.Errorf("expected size %d and received %d", len(expected), size)
+	}
+}
+
+func Test_CRLFCommitHeaders(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	// test_data/crlf-commit is the contents of a commit whose lines end
+	// in CRLF, with irregular spacing and headers that are not parsed
+	raw, err := ioutil.ReadFile(filepath.Join("test_data", "crlf-commit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := writeTestObject(t, repo, OBJ_COMMIT, raw)
+
+	obj, err := repo.Object(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, ok := obj.(Commit)
+	if !ok {
+		t.Fatalf("expected commit and received %s", obj.Type())
+	}
+
+	if commit.Tree != "0c8257b5f5348dc6cfd29e5e519d058535d5678f" {
+		t.Errorf("unexpected tree %q", commit.Tree)
+	}
+	if !reflect.DeepEqual([]SHA{"4bab381d0209b95160f8cc8761fe479ad72187d8"}, commit.Parents) {
+		t.Errorf("unexpected parents %q", commit.Parents)
+	}
+	if commit.Author != "aditya <dev@chimeracoder.net>" || commit.Committer != "aditya <dev@chimeracoder.net>" {
+		t.Errorf("unexpected author %q and committer %q", commit.Author, commit.Committer)
+	}
+	if commit.AuthorDate.Unix() != 1428611978 || commit.CommitterDate.Unix() != 1428611978 {
+		t.Errorf("unexpected dates %s and %s", commit.AuthorDate, commit.CommitterDate)
+	}
+
+	expected := []RawHeader{
+		{"encoding", "ISO-8859-1"},
+		{"gpgsig", "-----BEGIN PGP SIGNATURE-----\n\niQEcBAABAgAGBQJVJu/KAAoJEA==\n-----END PGP SIGNATURE-----"},
+		{"x-imported-from", "svn://example.com/trunk@1234"},
+	}
+	if !reflect.DeepEqual(expected, commit.ExtraHeaders) {
+		t.Errorf("Expected and result don't match:\n%q\n%q", expected, commit.ExtraHeaders)
+	}
+}
@@ -0,0 +1,136 @@
+package gitgo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// PackIndexError describes an inconsistency between
+// a packfile and its corresponding index file
+type PackIndexError struct {
+	Offset int
+	Name   SHA
+	Reason string
+
+	err error // the underlying error, if any
+}
+
+func (e *PackIndexError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("pack/idx mismatch at offset %d: %s", e.Offset, e.Reason)
+	}
+	return fmt.Sprintf("pack/idx mismatch for %s at offset %d: %s", e.Name, e.Offset, e.Reason)
+}
+
+// Unwrap returns the error that caused the inconsistency, such as
+// ErrUnknownObjectType, or nil if there is none
+func (e *PackIndexError) Unwrap() error {
+	return e.err
+}
+
+// CheckPackIndexConsistency checks that a packfile and its index agree:
+// every object that the index lists begins a valid object at the recorded
+// offset, every object in the packfile appears in the index, and the name
+// that the index records for each object matches the hash of its contents.
+// It returns a *PackIndexError describing the first inconsistency found.
+//
+// Unlike the pack checksum, this catches an index that has drifted
+// from its packfile, such as after a partial write.
+func CheckPackIndexConsistency(pf, inf io.Reader) error {
+	pack, err := ioutil.ReadAll(pf)
+	if err != nil {
+		return err
+	}
+	if len(pack) < 32 || string(pack[:4]) != "PACK" {
+		return fmt.Errorf("invalid packfile")
+	}
+	if v := bytesToNum(pack[4:8]); v != 2 {
+		return fmt.Errorf("cannot parse packfile with version %d", v)
+	}
+	numObjects := int(bytesToNum(pack[8:12]))
+
+	indexed, hashSize, err := parseIdx(inf, 2)
+	if err != nil {
+		return err
+	}
+	sort.Slice(indexed, func(i, j int) bool {
+		return indexed[i].Offset < indexed[j].Offset
+	})
+
+	// Walk through the packfile one object at a time. The objects are
+	// stored back-to-back, so the end of one object is the start of the next
+	r := errReadSeeker{bytes.NewReader(pack), nil}
+	byOffset := map[int]*packObject{}
+	var walked []*packObject
+	offset := 12
+	for i := 0; i < numObjects; i++ {
+		object := &packObject{Offset: offset}
+		readObjectHeader(&r, object, hashSize)
+		if r.err != nil {
+			return &PackIndexError{Offset: offset, Reason: fmt.Sprintf("could not read object header: %s", r.err)}
+		}
+		if !object._type.known() {
+			return &PackIndexError{Offset: offset, Reason: fmt.Sprintf("%s %d", ErrUnknownObjectType, object._type), err: ErrUnknownObjectType}
+		}
+
+		// bytes.Reader is an io.ByteReader, so the zlib reader
+		// will not read past the end of the compressed data
+		zr, err := zlib.NewReader(r.r)
+		if err != nil {
+			return &PackIndexError{Offset: offset, Reason: err.Error()}
+		}
+		object.Data, err = ioutil.ReadAll(zr)
+		if err != nil {
+			return &PackIndexError{Offset: offset, Reason: err.Error()}
+		}
+		if len(object.Data) != object.Size {
+			return &PackIndexError{Offset: offset, Reason: fmt.Sprintf("inflated to %d bytes (expected %d)", len(object.Data), object.Size)}
+		}
+
+		walked = append(walked, object)
+		byOffset[offset] = object
+		pos, _ := r.Seek(0, io.SeekCurrent)
+		offset = int(pos)
+	}
+	if offset != len(pack)-hashSize {
+		return &PackIndexError{Offset: offset, Reason: "unexpected data after the last object"}
+	}
+
+	byName := map[SHA]*packObject{}
+	for _, idxObject := range indexed {
+		object, ok := byOffset[idxObject.Offset]
+		if !ok {
+			return &PackIndexError{Offset: idxObject.Offset, Name: idxObject.Name, Reason: "offset does not begin an object in the packfile"}
+		}
+		object.Name = idxObject.Name
+		byName[object.Name] = object
+	}
+	for _, object := range walked {
+		if object.Name == "" {
+			return &PackIndexError{Offset: object.Offset, Reason: "object is missing from the index"}
+		}
+	}
+
+	for _, object := range walked {
+		if object._type == OBJ_OFS_DELTA {
+			base, ok := byOffset[object.baseOffset]
+			if !ok {
+				return &PackIndexError{Offset: object.Offset, Name: object.Name, Reason: fmt.Sprintf("delta base offset %d does not begin an object", object.baseOffset)}
+			}
+			object.BaseObjectName = base.Name
+		}
+	}
+	for _, object := range walked {
+		if err := object.Patch(byName); err != nil {
+			return &PackIndexError{Offset: object.Offset, Name: object.Name, Reason: err.Error()}
+		}
+		if actual := hashObject(object.Type(), object.PatchedData, hashSize); actual != object.Name {
+			return &PackIndexError{Offset: object.Offset, Name: object.Name, Reason: fmt.Sprintf("contents hash to %s", actual)}
+		}
+	}
+	return nil
+}
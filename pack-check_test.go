@@ -0,0 +1,112 @@
+package gitgo
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+func readTestPack(t *testing.T) (pack []byte, idx []byte) {
+	pack, err := ioutil.ReadFile(path.Join(RepoDir.Name(), "objects/pack/pack-d310969c4ba0ebfe725685fa577a1eec5ecb15b2.pack"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err = ioutil.ReadFile(path.Join(RepoDir.Name(), "objects/pack/pack-d310969c4ba0ebfe725685fa577a1eec5ecb15b2.idx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pack, idx
+}
+
+func Test_CheckPackIndexConsistency(t *testing.T) {
+	pack, idx := readTestPack(t)
+	err := CheckPackIndexConsistency(bytes.NewReader(pack), bytes.NewReader(idx))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_CheckPackIndexConsistencyMismatch(t *testing.T) {
+	const numObjects = 17
+	const namesStart = 8 + 256*4
+	const offsetsStart = namesStart + numObjects*(20+4)
+
+	type testCase struct {
+		description string
+		corrupt     func(idx []byte)
+		expected    string
+	}
+	cases := []testCase{
+		{"name does not match contents", func(idx []byte) {
+			// 05d3cc770bd3524cc25d47e083d8942ad25033f0 is the first name
+			idx[namesStart+19] ^= 0xff
+		}, "05d3cc770bd3524cc25d47e083d8942ad250330f at offset 2422: contents hash to 05d3cc770bd3524cc25d47e083d8942ad25033f0"},
+		{"offset does not begin an object", func(idx []byte) {
+			idx[offsetsStart+3]++
+		}, "offset does not begin an object"},
+		{"object is missing from the index", func(idx []byte) {
+			// list the second object's offset for the first object too
+			copy(idx[offsetsStart:offsetsStart+4], idx[offsetsStart+4:offsetsStart+8])
+		}, "object is missing from the index"},
+	}
+
+	for _, c := range cases {
+		pack, idx := readTestPack(t)
+		c.corrupt(idx)
+		err := CheckPackIndexConsistency(bytes.NewReader(pack), bytes.NewReader(idx))
+		if err == nil {
+			t.Errorf("%s: expected error", c.description)
+			continue
+		}
+		if _, ok := err.(*PackIndexError); !ok {
+			t.Errorf("%s: expected *PackIndexError and received %T", c.description, err)
+		}
+		if !strings.Contains(err.Error(), c.expected) {
+			t.Errorf("%s: expected error containing %q and received %q", c.description, c.expected, err)
+		}
+	}
+
+	// the first object, at offset 12, is given the reserved type 5
+	pack, idx := readTestPack(t)
+	pack[12] = pack[12]&^0x70 | 5<<4
+	err := CheckPackIndexConsistency(bytes.NewReader(pack), bytes.NewReader(idx))
+	if !errors.Is(err, ErrUnknownObjectType) {
+		t.Errorf("expected ErrUnknownObjectType and received %v", err)
+	}
+}
+
+func Test_CheckPackIndexConsistencySHA256(t *testing.T) {
+	const name = "test_data/sha256/pack-0547dfc63c73d6597bb2e233d141bfd853b0b5d19c19819c65cb99bb4bca232e"
+	pack, err := ioutil.ReadFile(name + ".pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := ioutil.ReadFile(name + ".idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CheckPackIndexConsistency(bytes.NewReader(pack), bytes.NewReader(idx))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_CheckPackIndexConsistencyRefDelta(t *testing.T) {
+	// e9f1816de795d8e46914856d53c0f1de4291ce89 is stored as a REF_DELTA
+	// against 8256068a7c531d9426f17127b2ba0c12d80fc1d8
+	pack, err := ioutil.ReadFile("test_data/ref-delta/pack-8e8db833d33269b14d8cdee0d55f745e30f449c3.pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := ioutil.ReadFile("test_data/ref-delta/pack-8e8db833d33269b14d8cdee0d55f745e30f449c3.idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CheckPackIndexConsistency(bytes.NewReader(pack), bytes.NewReader(idx))
+	if err != nil {
+		t.Error(err)
+	}
+}
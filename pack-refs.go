@@ -0,0 +1,124 @@
+package gitgo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PackRefs implements git pack-refs. It moves loose refs into the
+// packed-refs file, peeling annotated tags along the way, and then
+// removes the loose ref files that are now redundant.
+// If all is false, only tags (and refs that are already packed)
+// are packed, which matches the default behavior of git.
+func PackRefs(repo *Repository, all bool) error {
+	dir, err := repo.commonGitDir()
+	if err != nil {
+		return err
+	}
+
+	// Holding the packed-refs lock prevents any other writer
+	// from packing or deleting refs until we are done
+	lock, err := lockFile(filepath.Join(dir, "packed-refs"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if lock != nil {
+			lock.Close()
+			os.Remove(lock.Name())
+		}
+	}()
+
+	packed, err := readPackedRefs(dir)
+	if err != nil {
+		return err
+	}
+	loose, err := readLooseRefs(dir)
+	if err != nil {
+		return err
+	}
+
+	refs := map[string]Ref{}
+	for _, ref := range packed {
+		refs[ref.Name] = ref
+	}
+
+	var pruned []Ref
+	for _, ref := range loose {
+		_, alreadyPacked := refs[ref.Name]
+		if !all && !alreadyPacked && !strings.HasPrefix(ref.Name, "refs/tags/") {
+			continue
+		}
+		refs[ref.Name] = ref
+		pruned = append(pruned, ref)
+	}
+
+	result := sortRefs(refs)
+	for i, ref := range result {
+		if ref.Peeled != "" {
+			continue
+		}
+		peeled, err := repo.peel(ref.Target)
+		if err != nil {
+			return err
+		}
+		result[i].Peeled = peeled
+	}
+
+	err = writePackedRefs(lock, dir, result)
+	if err != nil {
+		return err
+	}
+	lock = nil
+
+	for _, ref := range pruned {
+		if err := pruneLooseRef(dir, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneLooseRef removes the loose ref file for ref, but only if it
+// still points to the same object that was written to packed-refs.
+// If the ref is locked or has been updated in the meantime, it is left alone.
+func pruneLooseRef(dir string, ref Ref) error {
+	path := filepath.Join(dir, filepath.FromSlash(ref.Name))
+	lock, err := lockFile(path)
+	if err != nil {
+		// another process is updating this ref
+		return nil
+	}
+	err = removeLooseRef(path, ref.Target)
+	lock.Close()
+	os.Remove(lock.Name())
+	if err != nil {
+		return err
+	}
+
+	// Remove any directories left empty, but keep the
+	// top-level directories (such as refs/heads) in place
+	refsDir := filepath.Join(dir, "refs")
+	for parent := filepath.Dir(path); parent != refsDir && filepath.Dir(parent) != refsDir; parent = filepath.Dir(parent) {
+		if os.Remove(parent) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// removeLooseRef removes the ref file at path if it points to target
+func removeLooseRef(path string, target SHA) error {
+	current, _, err := readLooseRef(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if current != target {
+		return nil
+	}
+	return os.Remove(path)
+}
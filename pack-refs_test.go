@@ -0,0 +1,83 @@
+package gitgo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_PackRefs(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+	dir := repo.Basedir.Name()
+
+	before, err := repo.Refs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = PackRefs(repo, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "refs", "tags", "0.1")); !os.IsNotExist(err) {
+		t.Errorf("expected loose tag to be removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "refs", "heads", "master")); err != nil {
+		t.Errorf("expected loose branch to be kept: %s", err)
+	}
+
+	packed, err := readPackedRefs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Ref{
+		// the loose ref was already packed, so its newer value replaces the stale packed one
+		{Name: "refs/remotes/origin/master", Target: "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{Name: "refs/tags/0.1", Target: "49bac2b0a923fe6481c7cc207837cf663748c1ed", Peeled: "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+	}
+	if !reflect.DeepEqual(expected, packed) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, packed)
+	}
+
+	err = PackRefs(repo, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "refs", "heads", "master")); !os.IsNotExist(err) {
+		t.Errorf("expected loose branch to be removed: %v", err)
+	}
+
+	after, err := repo.Refs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range before {
+		// packing adds peel information, but should not change any targets
+		before[i].Peeled = after[i].Peeled
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Errorf("refs changed after packing:\n%+v\n%+v", before, after)
+	}
+}
+
+func Test_PackRefsLocked(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+	dir := repo.Basedir.Name()
+
+	lock, err := lockFile(filepath.Join(dir, "packed-refs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Close()
+
+	if err := PackRefs(repo, true); err == nil {
+		t.Errorf("expected error when packed-refs is locked")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "refs", "heads", "master")); err != nil {
+		t.Errorf("expected loose branch to be kept: %s", err)
+	}
+}
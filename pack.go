@@ -2,12 +2,17 @@ package gitgo
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type packfile struct {
@@ -58,6 +63,14 @@ type packObject struct {
 	baseOffset     int
 	Depth          int
 
+	// the offset of the compressed object data,
+	// immediately after the object header
+	dataOffset int
+
+	// if non-nil, PatchedData has been written to a spill file
+	// (see VerifyPackSpill) rather than held in memory
+	spill *spillHandle
+
 	err error // was an error encountered while processing this object?
 }
 
@@ -69,6 +82,8 @@ func (p *packObject) Type() string {
 		return "tree"
 	case OBJ_BLOB:
 		return "blob"
+	case OBJ_TAG:
+		return "tag"
 	default:
 		return p.BaseObjectType.String()
 	}
@@ -86,6 +101,8 @@ func (p *packObject) normalize(basedir os.File) (GitObject, error) {
 		return p.Tree(basedir)
 	case OBJ_BLOB:
 		return p.Blob(basedir)
+	case OBJ_TAG:
+		return p.Tag(basedir)
 	default:
 		return p, nil
 	}
@@ -96,12 +113,13 @@ func (p *packObject) Commit(basedir os.File) (Commit, error) {
 	if p.BaseObjectType != OBJ_COMMIT {
 		return Commit{}, fmt.Errorf("pack object is not a commit: %s", p.Type())
 	}
-	if p.PatchedData == nil {
-		p.PatchedData = p.Data
+	data, err := p.contents()
+	if err != nil {
+		return Commit{}, err
 	}
 
-	commit, err := parseCommit(bytes.NewReader(p.PatchedData), strconv.Itoa(p.Size), p.Name)
-	commit.rawData = p.PatchedData
+	commit, err := parseCommit(bytes.NewReader(data), strconv.Itoa(p.Size), p.Name)
+	commit.rawData = data
 	return commit, err
 }
 
@@ -110,11 +128,12 @@ func (p *packObject) Tree(basedir os.File) (Tree, error) {
 	if p.BaseObjectType != OBJ_TREE {
 		return Tree{}, fmt.Errorf("pack object is not a tree: %s", p.Type())
 	}
-	if p.PatchedData == nil {
-		p.PatchedData = p.Data
+	data, err := p.contents()
+	if err != nil {
+		return Tree{}, err
 	}
 
-	tree, err := parseTree(bytes.NewReader(p.PatchedData), strconv.Itoa(p.Size), basedir)
+	tree, err := parseTree(bytes.NewReader(data), strconv.Itoa(p.Size))
 	return tree, err
 }
 
@@ -123,17 +142,81 @@ func (p *packObject) Blob(basedir os.File) (Blob, error) {
 	if p.BaseObjectType != OBJ_BLOB {
 		return Blob{}, fmt.Errorf("pack object is not a blob: %s", p.Type())
 	}
-	if p.PatchedData == nil {
-		p.PatchedData = p.Data
+	data, err := p.contents()
+	if err != nil {
+		return Blob{}, err
 	}
 
 	// TODO fix the size param
-	blob, err := parseBlob(bytes.NewReader(p.PatchedData), "")
-	blob.rawData = p.PatchedData
+	blob, err := parseBlob(bytes.NewReader(data), "")
+	blob.rawData = data
 	return blob, err
 }
 
+// Tag returns a Tag struct for the packObject.
+func (p *packObject) Tag(basedir os.File) (Tag, error) {
+	if p.BaseObjectType != OBJ_TAG {
+		return Tag{}, fmt.Errorf("pack object is not a tag: %s", p.Type())
+	}
+	data, err := p.contents()
+	if err != nil {
+		return Tag{}, err
+	}
+
+	return parseTag(bytes.NewReader(data), strconv.Itoa(p.Size), p.Name)
+}
+
+// contents returns the (patched) contents of the object,
+// reading them back from the spill file if necessary
+func (p *packObject) contents() ([]byte, error) {
+	if p.PatchedData == nil && p.spill != nil {
+		return ioutil.ReadAll(p.Reader())
+	}
+	if p.PatchedData == nil {
+		p.PatchedData = p.Data
+	}
+	return p.PatchedData, nil
+}
+
+// objectSize returns the size of the (patched) contents of the object.
+// If the object is a delta that has not been patched, the size is read
+// from the delta header instead of applying the delta.
+func (p *packObject) objectSize() (int, error) {
+	switch {
+	case p.PatchedData != nil:
+		return len(p.PatchedData), nil
+	case p.spill != nil:
+		return int(p.spill.size), nil
+	case p._type < OBJ_OFS_DELTA:
+		return p.Size, nil
+	}
+
+	// the delta begins with the sizes of the base and the result
+	r := bytes.NewReader(p.Data)
+	if _, err := parseVarInt(r); err != nil {
+		return 0, err
+	}
+	return parseVarInt(r)
+}
+
+// Reader returns a reader for the (patched) contents of the object.
+// If the object was resolved by VerifyPackSpill, the contents are
+// read from the spill file on demand.
+func (p *packObject) Reader() io.Reader {
+	if p.PatchedData == nil && p.spill != nil {
+		return p.spill.reader()
+	}
+	return bytes.NewReader(p.PatchedData)
+}
+
 func (p *packObject) Patch(dict map[SHA]*packObject) error {
+	return p.patch(dict, nil)
+}
+
+// patch is like Patch, but delta bases are looked up in cache
+// before dict, and every base that is used is added to cache.
+// cache may be nil.
+func (p *packObject) patch(dict map[SHA]*packObject, cache *DeltaBaseCache) error {
 	if len(p.PatchedData) != 0 {
 		return nil
 	}
@@ -147,17 +230,28 @@ func (p *packObject) Patch(dict map[SHA]*packObject) error {
 	}
 
 	if p._type >= OBJ_OFS_DELTA {
-		base, ok := dict[p.BaseObjectName]
-		if !ok {
-			return fmt.Errorf("base object not in dictionary: %s", p.BaseObjectName)
+		var base deltaBase
+		var cached bool
+		if cache != nil {
+			base, cached = cache.get(p.BaseObjectName)
 		}
-		err := base.Patch(dict)
-		if err != nil {
-			return err
+		if !cached {
+			baseObject, ok := dict[p.BaseObjectName]
+			if !ok {
+				return fmt.Errorf("base object not in dictionary: %s", p.BaseObjectName)
+			}
+			err := baseObject.patch(dict, cache)
+			if err != nil {
+				return err
+			}
+			// At this point, we know that the baseObject.PatchedData is non-nil
+			base = deltaBase{baseObject.BaseObjectType, baseObject.PatchedData, baseObject.Depth}
+			if cache != nil {
+				cache.add(baseObject.Name, base)
+			}
 		}
 
-		// At the time patchDelta is called, we know that the base.PatchedData is non-nil
-		patched, err := patchDelta(bytes.NewReader(base.PatchedData), bytes.NewReader(p.Data))
+		patched, err := patchDelta(bytes.NewReader(base.data), bytes.NewReader(p.Data))
 		if err != nil {
 			return err
 		}
@@ -167,8 +261,8 @@ func (p *packObject) Patch(dict map[SHA]*packObject) error {
 			return err
 		}
 
-		p.BaseObjectType = base.BaseObjectType
-		p.Depth += base.Depth
+		p.BaseObjectType = base._type
+		p.Depth += base.depth
 	}
 	return nil
 }
@@ -194,8 +288,36 @@ const (
 	OBJ_REF_DELTA
 )
 
+// ErrUnknownObjectType is returned when a packfile contains an object
+// whose type is not one of the types above (such as 0 or 5, which are
+// reserved), which usually means that the packfile is corrupt
+var ErrUnknownObjectType = errors.New("unknown pack object type")
+
+// known returns true if t is one of the object types
+// defined by the packfile format
+func (t packObjectType) known() bool {
+	switch t {
+	case OBJ_COMMIT, OBJ_TREE, OBJ_BLOB, OBJ_TAG, OBJ_OFS_DELTA, OBJ_REF_DELTA:
+		return true
+	}
+	return false
+}
+
 func (r *Repository) listPackfiles() ([]*packfile, error) {
 	basedir := r.Basedir
+	common, err := commonDir(basedir.Name())
+	if err != nil {
+		return nil, err
+	}
+	if common != basedir.Name() {
+		// a linked worktree shares the packfiles of the main repository
+		f, err := os.Open(common)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		basedir = *f
+	}
 	files, err := ioutil.ReadDir(filepath.Join(basedir.Name(), "objects", "pack"))
 	if err != nil {
 		return nil, err
@@ -209,14 +331,102 @@ func (r *Repository) listPackfiles() ([]*packfile, error) {
 		}
 		packfileNames = append(packfileNames, SHA(base))
 	}
+
+	// each packfile is independent, so they are parsed concurrently,
+	// using at most r.PackConcurrency workers
+	workers := r.PackConcurrency
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	sem := make(chan struct{}, workers)
 	packs := make([]*packfile, len(packfileNames))
+	errs := make([]error, len(packfileNames))
+	var wg sync.WaitGroup
 	for i, n := range packfileNames {
-		p := &packfile{basedir: basedir, name: n}
-		err = p.verify()
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, n SHA) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			p := &packfile{basedir: basedir, name: n}
+			errs[i] = p.verify()
+			packs[i] = p
+		}(i, n)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
 		if err != nil {
 			return nil, err
 		}
-		packs[i] = p
 	}
 	return packs, nil
 }
+
+// packIndex is the table of object names from a version 2 index file.
+// It is used to check whether a packfile contains an object without
+// parsing the packfile (or the rest of the index).
+type packIndex struct {
+	// fanout[b] is the number of objects whose
+	// names begin with a byte less than or equal to b
+	fanout [256]int
+
+	// names holds the sorted object names, hashSize bytes each
+	names    []byte
+	hashSize int
+}
+
+// readPackIndex reads the fanout and name tables of the index at path
+func readPackIndex(path string) (*packIndex, error) {
+	bts, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	const headerSize = 8 + 256*4
+	if len(bts) < headerSize || !bytes.Equal(bts[:4], []byte{255, 116, 79, 99}) {
+		return nil, fmt.Errorf("invalid IDX header: %s", path)
+	}
+	if v := bytesToNum(bts[4:8]); v != 2 {
+		return nil, fmt.Errorf("cannot parse IDX with version %d: %s", v, path)
+	}
+
+	idx := &packIndex{}
+	for i := range idx.fanout {
+		idx.fanout[i] = int(bytesToNum(bts[8+i*4 : 8+(i+1)*4]))
+		if i > 0 && idx.fanout[i] < idx.fanout[i-1] {
+			return nil, fmt.Errorf("invalid IDX fanout table: %s", path)
+		}
+	}
+	numObjects := idx.fanout[255]
+	rest := bts[headerSize:]
+	idx.hashSize, err = idxHashSize(rest, numObjects)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	idx.names = rest[:numObjects*idx.hashSize]
+	return idx, nil
+}
+
+// contains returns true if the index lists the object with the
+// given (binary) name. The fanout table gives the range of objects
+// that begin with the same byte, which is then binary searched.
+func (idx *packIndex) contains(name []byte) bool {
+	if len(name) != idx.hashSize {
+		return false
+	}
+	lo := 0
+	if name[0] > 0 {
+		lo = idx.fanout[name[0]-1]
+	}
+	hi := idx.fanout[name[0]]
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		start := (lo + i) * idx.hashSize
+		return bytes.Compare(idx.names[start:start+idx.hashSize], name) >= 0
+	})
+	if i >= hi {
+		return false
+	}
+	return bytes.Equal(idx.names[i*idx.hashSize:(i+1)*idx.hashSize], name)
+}
@@ -0,0 +1,57 @@
+package gitgo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnexpectedObjectType is returned when an object
+// is not of the type that the caller expected
+var ErrUnexpectedObjectType = errors.New("unexpected object type")
+
+// unexpectedType returns an error wrapping ErrUnexpectedObjectType,
+// which reports the actual type of obj
+func unexpectedType(name SHA, obj GitObject, expected string) error {
+	return fmt.Errorf("%w: %s is a %s, not a %s", ErrUnexpectedObjectType, name, obj.Type(), expected)
+}
+
+// ReadBlob returns the blob with the given name (or unique prefix).
+// If the object is not a blob, the error wraps ErrUnexpectedObjectType.
+func ReadBlob(repo *Repository, name SHA) (Blob, error) {
+	obj, err := repo.Object(name)
+	if err != nil {
+		return Blob{}, err
+	}
+	blob, ok := obj.(Blob)
+	if !ok {
+		return Blob{}, unexpectedType(name, obj, "blob")
+	}
+	return blob, nil
+}
+
+// ReadCommit returns the commit with the given name (or unique prefix).
+// If the object is not a commit, the error wraps ErrUnexpectedObjectType.
+// Tags are not peeled.
+func ReadCommit(repo *Repository, name SHA) (Commit, error) {
+	return repo.commit(name)
+}
+
+// ReadTree returns the tree with the given name (or unique prefix).
+// If the object is not a tree, the error wraps ErrUnexpectedObjectType.
+func ReadTree(repo *Repository, name SHA) (Tree, error) {
+	return repo.tree(name)
+}
+
+// ReadTag returns the annotated tag with the given name (or unique prefix).
+// If the object is not a tag, the error wraps ErrUnexpectedObjectType.
+func ReadTag(repo *Repository, name SHA) (Tag, error) {
+	obj, err := repo.Object(name)
+	if err != nil {
+		return Tag{}, err
+	}
+	tag, ok := obj.(Tag)
+	if !ok {
+		return Tag{}, unexpectedType(name, obj, "tag")
+	}
+	return tag, nil
+}
@@ -0,0 +1,38 @@
+package gitgo
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_ReadTypedObjects(t *testing.T) {
+	repo := &Repository{Basedir: *RepoDir}
+	const (
+		commit = SHA("37213e7bb3c334a0f7708c7afcab5babb3f95434")
+		tree   = SHA("0c8257b5f5348dc6cfd29e5e519d058535d5678f")
+		blob   = SHA("c9b4a98fd0720609e086293d53a28b1ad8d41c55")
+		tag    = SHA("49bac2b0a923fe6481c7cc207837cf663748c1ed")
+	)
+	read := map[string]func(SHA) error{
+		"blob":   func(name SHA) error { _, err := ReadBlob(repo, name); return err },
+		"commit": func(name SHA) error { _, err := ReadCommit(repo, name); return err },
+		"tree":   func(name SHA) error { _, err := ReadTree(repo, name); return err },
+		"tag":    func(name SHA) error { _, err := ReadTag(repo, name); return err },
+	}
+	names := map[string]SHA{"blob": blob, "commit": commit, "tree": tree, "tag": tag}
+
+	for expectedType, fn := range read {
+		for actualType, name := range names {
+			err := fn(name)
+			if actualType == expectedType {
+				if err != nil {
+					t.Errorf("reading %s as a %s: %s", name, expectedType, err)
+				}
+				continue
+			}
+			if !errors.Is(err, ErrUnexpectedObjectType) {
+				t.Errorf("reading a %s as a %s: expected ErrUnexpectedObjectType and received %v", actualType, expectedType, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package gitgo
+
+import (
+	"strings"
+)
+
+// zeroSHA is the object name used by the push protocol
+// for a ref that does not exist (before it is created,
+// or after it is deleted). In a SHA-256 repository,
+// the name is 64 zeros instead.
+const zeroSHA = SHA("0000000000000000000000000000000000000000")
+
+// isZeroSHA returns true if name is the all-zero
+// object name, for either SHA-1 or SHA-256
+func isZeroSHA(name SHA) bool {
+	return validObjectName(name) && strings.Trim(string(name), "0") == ""
+}
+
+// A RefUpdate is a change to a ref requested by a push,
+// from Old to New. Old is zeroSHA if the ref is being created,
+// and New is zeroSHA if it is being deleted.
+type RefUpdate struct {
+	Name string
+	Old  SHA
+	New  SHA
+}
+
+// RefUpdateStatus reports whether a RefUpdate is accepted.
+// If it is rejected, Reason explains why, using the same
+// wording as git's report-status (such as "non-fast-forward").
+type RefUpdateStatus struct {
+	Accepted bool
+	Reason   string
+}
+
+// ValidateRefUpdates checks the ref updates requested by a push against
+// the repository's receive.* configuration, before any of them are applied,
+// as git receive-pack does. It returns the status of each update, keyed by
+// ref name. The following updates are rejected:
+//
+//	an invalid ref name (see ValidRefName)
+//	deleting a branch, if receive.denyDeletes is set
+//	a non-fast-forward update to a branch, if receive.denyNonFastForwards
+//	is set (an update is a fast-forward if Old is an ancestor of New)
+//
+// If receive.denyNonFastForwards is set, and Old or New is not a commit
+// in the repository, the update is rejected as a "bad ref".
+//
+// The updates are not applied, and the current values of the refs are
+// not compared against Old; that is left to the code that updates them.
+func ValidateRefUpdates(repo *Repository, updates []RefUpdate) (map[string]RefUpdateStatus, error) {
+	config, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	denyDeletes, err := config.Bool("receive.denyDeletes")
+	if err != nil {
+		return nil, err
+	}
+	denyNonFastForwards, err := config.Bool("receive.denyNonFastForwards")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]RefUpdateStatus, len(updates))
+	for _, update := range updates {
+		reject := func(reason string) {
+			result[update.Name] = RefUpdateStatus{Reason: reason}
+		}
+		branch := strings.HasPrefix(update.Name, "refs/heads/")
+		switch {
+		case !strings.HasPrefix(update.Name, "refs/") || ValidRefName(update.Name) != nil:
+			reject("funny refname")
+		case isZeroSHA(update.New) && denyDeletes && branch:
+			reject("deletion prohibited")
+		case !isZeroSHA(update.Old) && !isZeroSHA(update.New) && denyNonFastForwards && branch:
+			result[update.Name] = checkFastForward(repo, update.Old, update.New)
+		default:
+			result[update.Name] = RefUpdateStatus{Accepted: true}
+		}
+	}
+	return result, nil
+}
+
+// checkFastForward returns the status of an update from old to updated
+// when non-fast-forward updates are denied. An update whose old or new
+// value is missing, cannot be read, or is not a commit is a "bad ref".
+func checkFastForward(repo *Repository, old, updated SHA) RefUpdateStatus {
+	for _, name := range []SHA{old, updated} {
+		obj, err := repo.Object(name)
+		if err != nil {
+			return RefUpdateStatus{Reason: "bad ref"}
+		}
+		if _, ok := obj.(Commit); !ok {
+			return RefUpdateStatus{Reason: "bad ref"}
+		}
+	}
+	ff, err := isAncestor(repo, old, updated)
+	if err != nil {
+		return RefUpdateStatus{Reason: "bad ref"}
+	}
+	if !ff {
+		return RefUpdateStatus{Reason: "non-fast-forward"}
+	}
+	return RefUpdateStatus{Accepted: true}
+}
+
+// isAncestor returns true if the commit ancestor is reachable from
+// (or is the same as) the commit descendant
+func isAncestor(repo *Repository, ancestor, descendant SHA) (bool, error) {
+	seen := map[SHA]bool{descendant: true}
+	pending := []SHA{descendant}
+	for len(pending) > 0 {
+		name := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if name == ancestor {
+			return true, nil
+		}
+		parents, err := repo.ParentsOf(name)
+		if err != nil {
+			return false, err
+		}
+		for _, parent := range parents {
+			if !seen[parent] {
+				seen[parent] = true
+				pending = append(pending, parent)
+			}
+		}
+	}
+	return false, nil
+}
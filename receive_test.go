@@ -0,0 +1,80 @@
+package gitgo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ValidateRefUpdates(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	const (
+		head   = SHA("37213e7bb3c334a0f7708c7afcab5babb3f95434")
+		older  = SHA("b499a18d0aea475863ef88dcdb0941ca71a53b13")
+		tagObj = SHA("49bac2b0a923fe6481c7cc207837cf663748c1ed")
+	)
+	updates := []RefUpdate{
+		{"refs/heads/master", older, head},
+		{"refs/heads/rewind", head, older},
+		{"refs/heads/tagged", tagObj, head},
+		{"refs/heads/gone", head, zeroSHA},
+		{"refs/heads/gone256", head, SHA(strings.Repeat("0", 64))},
+		{"refs/heads/new", zeroSHA, head},
+		{"refs/heads/missing", "1111111111111111111111111111111111111111", head},
+		{"refs/tags/moved", head, older},
+		{"refs/tags/gone", head, zeroSHA},
+		{"refs/heads/bad..name", zeroSHA, head},
+		{"master", older, head},
+	}
+
+	// by default, only invalid names are rejected
+	accepted := RefUpdateStatus{Accepted: true}
+	expected := map[string]RefUpdateStatus{
+		"refs/heads/master":    accepted,
+		"refs/heads/rewind":    accepted,
+		"refs/heads/tagged":    accepted,
+		"refs/heads/gone":      accepted,
+		"refs/heads/gone256":   accepted,
+		"refs/heads/new":       accepted,
+		"refs/heads/missing":   accepted,
+		"refs/tags/moved":      accepted,
+		"refs/tags/gone":       accepted,
+		"refs/heads/bad..name": {Reason: "funny refname"},
+		"master":               {Reason: "funny refname"},
+	}
+	result, err := ValidateRefUpdates(repo, updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, result)
+	}
+
+	config, err := os.OpenFile(filepath.Join(repo.Basedir.Name(), "config"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = config.WriteString("[receive]\n\tdenyDeletes = true\n\tdenyNonFastForwards = true\n")
+	config.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only branches are protected
+	expected["refs/heads/rewind"] = RefUpdateStatus{Reason: "non-fast-forward"}
+	expected["refs/heads/tagged"] = RefUpdateStatus{Reason: "bad ref"}
+	expected["refs/heads/gone"] = RefUpdateStatus{Reason: "deletion prohibited"}
+	expected["refs/heads/gone256"] = RefUpdateStatus{Reason: "deletion prohibited"}
+	expected["refs/heads/missing"] = RefUpdateStatus{Reason: "bad ref"}
+	result, err = ValidateRefUpdates(repo, updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, result)
+	}
+}
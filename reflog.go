@@ -0,0 +1,107 @@
+package gitgo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrReflogNotFound is returned by Reflog when a ref has no reflog
+var ErrReflogNotFound = errors.New("no reflog")
+
+// ReflogEntry is a single entry in a reflog, recording
+// that a ref was updated from Old to New
+type ReflogEntry struct {
+	Old       SHA
+	New       SHA
+	Committer string
+	Date      time.Time
+	Message   string
+}
+
+// Reflog returns the reflog for the ref with the given full name
+// (such as "HEAD" or "refs/heads/master"), oldest entry first.
+func (r *Repository) Reflog(ref string) ([]ReflogEntry, error) {
+	dir, err := r.gitDir()
+	if err != nil {
+		return nil, err
+	}
+	if !isPerWorktreeRef(ref) {
+		if dir, err = commonDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.Open(filepath.Join(dir, "logs", filepath.FromSlash(ref)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w for %s", ErrReflogNotFound, ref)
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []ReflogEntry
+	scnr := bufio.NewScanner(f)
+	for scnr.Scan() {
+		if scnr.Text() == "" {
+			continue
+		}
+		entry, err := parseReflogLine(scnr.Text())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scnr.Err()
+}
+
+// parseReflogLine parses a line of the form
+// <old> <new> <committer> <timestamp> <timezone>\t<message>
+func parseReflogLine(line string) (ReflogEntry, error) {
+	var entry ReflogEntry
+	var message string
+	if i := strings.Index(line, "\t"); i >= 0 {
+		line, message = line[:i], line[i+1:]
+	}
+
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) != 3 || len(fields[0]) != 40 || len(fields[1]) != 40 {
+		return entry, fmt.Errorf("malformed reflog entry: %s", line)
+	}
+
+	committer, date, err := parseCommitterString(fields[2])
+	if err != nil {
+		return entry, err
+	}
+	entry.Old = SHA(fields[0])
+	entry.New = SHA(fields[1])
+	entry.Committer = committer
+	entry.Date = date
+	entry.Message = message
+	return entry, nil
+}
+
+// RefAtTime returns the value that the ref with the given full name
+// had at time t, according to its reflog (as in master@{2015-04-10}).
+// This is the value recorded by the last reflog entry at or before t.
+func (r *Repository) RefAtTime(ref string, t time.Time) (SHA, error) {
+	entries, err := r.Reflog(ref)
+	if err != nil {
+		return "", err
+	}
+
+	// entries are in chronological order, so find
+	// the first entry that is after t
+	i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].Date.After(t)
+	})
+	if i == 0 {
+		return "", fmt.Errorf("log for %s does not go back to %s", ref, t)
+	}
+	return entries[i-1].New, nil
+}
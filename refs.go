@@ -0,0 +1,600 @@
+package gitgo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// packedRefsHeader is written at the top of every packed-refs file.
+// "fully-peeled" promises that every ref which can be peeled
+// is followed by a peel line
+const packedRefsHeader = "# pack-refs with: peeled fully-peeled sorted \n"
+
+// maxSymrefDepth is the number of symbolic refs that will be followed
+// before giving up, in case of a loop
+const maxSymrefDepth = 5
+
+// ErrRefNotFound is returned when a ref does not exist
+var ErrRefNotFound = errors.New("ref not found")
+
+// ErrInvalidRefName is returned for ref names that git does not allow
+var ErrInvalidRefName = errors.New("invalid ref name")
+
+// Ref is a named reference to an object. If the ref points
+// to an annotated tag, Peeled is the object that the tag
+// (eventually) points to.
+type Ref struct {
+	Name   string
+	Target SHA
+	Peeled SHA
+}
+
+// gitDir returns the path to the .git directory of the repository
+func (r *Repository) gitDir() (string, error) {
+	if filepath.Base(r.Basedir.Name()) != ".git" && !isGitDir(r.Basedir.Name()) {
+		if err := r.normalizeBasename(); err != nil {
+			return "", err
+		}
+	}
+	return r.Basedir.Name(), nil
+}
+
+// commonGitDir returns the path to the directory that holds the parts
+// of the repository shared between worktrees: the object store and
+// the refs (other than per-worktree refs, such as HEAD). Except in
+// a linked worktree, this is the same as gitDir.
+func (r *Repository) commonGitDir() (string, error) {
+	dir, err := r.gitDir()
+	if err != nil {
+		return "", err
+	}
+	return commonDir(dir)
+}
+
+// Refs returns all of the refs in the repository, sorted by name.
+// Loose refs take precedence over packed refs with the same name.
+// Symbolic refs are not included.
+func (r *Repository) Refs() ([]Ref, error) {
+	dir, err := r.gitDir()
+	if err != nil {
+		return nil, err
+	}
+	common, err := commonDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	packed, err := readPackedRefs(common)
+	if err != nil {
+		return nil, err
+	}
+	loose, err := readLooseRefs(common)
+	if err != nil {
+		return nil, err
+	}
+	if common != dir {
+		// a linked worktree's own refs, such as refs/bisect/*
+		worktreeRefs, err := readLooseRefs(dir)
+		if err != nil {
+			return nil, err
+		}
+		loose = append(loose, worktreeRefs...)
+	}
+
+	refs := map[string]Ref{}
+	for _, ref := range packed {
+		refs[ref.Name] = ref
+	}
+	for _, ref := range loose {
+		refs[ref.Name] = ref
+	}
+	return sortRefs(refs), nil
+}
+
+func sortRefs(refs map[string]Ref) []Ref {
+	result := make([]Ref, 0, len(refs))
+	for _, ref := range refs {
+		result = append(result, ref)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// readPackedRefs parses the packed-refs file in the git directory.
+// If there is no packed-refs file, it returns no refs and no error.
+func readPackedRefs(dir string) ([]Ref, error) {
+	f, err := os.Open(filepath.Join(dir, "packed-refs"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var refs []Ref
+	scnr := bufio.NewScanner(f)
+	for scnr.Scan() {
+		line := strings.TrimSpace(scnr.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "^") {
+			// a peel line applies to the ref immediately before it
+			if len(refs) == 0 {
+				return nil, fmt.Errorf("packed-refs peel line without a ref: %s", line)
+			}
+			refs[len(refs)-1].Peeled = SHA(line[1:])
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("malformed packed-refs line: %s", line)
+		}
+		refs = append(refs, Ref{Name: fields[1], Target: SHA(fields[0])})
+	}
+	return refs, scnr.Err()
+}
+
+// readLooseRefs reads every ref stored as a file underneath
+// the refs/ directory.
+func readLooseRefs(dir string) ([]Ref, error) {
+	var refs []Ref
+	refsDir := filepath.Join(dir, "refs")
+	err := filepath.Walk(refsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == refsDir {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+		target, symref, err := readLooseRef(path)
+		if err != nil {
+			return err
+		}
+		if symref != "" {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		refs = append(refs, Ref{Name: filepath.ToSlash(name), Target: target})
+		return nil
+	})
+	return refs, err
+}
+
+// readLooseRef returns the object that the ref file points to.
+// If the ref is a symbolic ref, it instead returns the name of the
+// ref that it points to.
+func readLooseRef(path string) (target SHA, symref string, err error) {
+	bts, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	bts = bytes.TrimSpace(bts)
+	if bytes.HasPrefix(bts, []byte("ref:")) {
+		return "", string(bytes.TrimSpace(bts[4:])), nil
+	}
+
+	// Some files, such as FETCH_HEAD, contain more than
+	// one line or have information after the object name
+	fields := bytes.Fields(bts)
+	if len(fields) == 0 || len(fields[0]) != 40 {
+		return "", "", fmt.Errorf("malformed ref %s: %q", path, bts)
+	}
+	return SHA(fields[0]), "", nil
+}
+
+// readRef reads the ref with the given full name, preferring a loose
+// ref over a packed ref. If the ref is symbolic, it returns the name
+// of the ref it points to instead of an object name. dir is the git
+// directory; in a linked worktree, only per-worktree refs are read
+// from it, and the rest are read from its common directory.
+func readRef(dir string, name string) (target SHA, symref string, err error) {
+	common, err := commonDir(dir)
+	if err != nil {
+		return "", "", err
+	}
+	if !isPerWorktreeRef(name) {
+		dir = common
+	}
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if info, statErr := os.Stat(path); statErr == nil && !info.IsDir() {
+		return readLooseRef(path)
+	} else if statErr != nil && !os.IsNotExist(statErr) {
+		return "", "", statErr
+	}
+
+	ref, ok, err := lookupPackedRef(common, name)
+	if err != nil {
+		return "", "", err
+	}
+	if ok {
+		return ref.Target, "", nil
+	}
+	return "", "", fmt.Errorf("%w: %s", ErrRefNotFound, name)
+}
+
+// lookupPackedRef finds a single ref in the packed-refs file without
+// parsing the rest of the file. If the file is sorted (as git always
+// writes it), the ref is found by binary search. Otherwise, the lines
+// are scanned in order. If there is no such ref, ok is false.
+func lookupPackedRef(dir string, name string) (ref Ref, ok bool, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "packed-refs"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Ref{}, false, nil
+		}
+		return Ref{}, false, err
+	}
+
+	var start int
+	var sorted bool
+	if bytes.HasPrefix(data, []byte("# pack-refs with:")) {
+		eol := bytes.IndexByte(data, '\n')
+		if eol < 0 {
+			return Ref{}, false, nil
+		}
+		// the traits are separated by spaces, including after the last one
+		sorted = bytes.Contains(data[:eol], []byte(" sorted "))
+		start = eol + 1
+	}
+
+	// Each record is a ref line, optionally followed by a peel line
+	// that begins with '^'
+	if sorted {
+		lo, hi := start, len(data)
+		for lo < hi {
+			rec := packedRecordStart(data, lo, lo+(hi-lo)/2)
+			recName, err := packedRecordName(data, rec)
+			if err != nil {
+				return Ref{}, false, err
+			}
+			switch strings.Compare(recName, name) {
+			case -1:
+				lo = packedRecordEnd(data, rec, hi)
+			case 1:
+				hi = rec
+			default:
+				ref, err := parsePackedRecord(data, rec)
+				return ref, err == nil, err
+			}
+		}
+		return Ref{}, false, nil
+	}
+
+	for rec := start; rec < len(data); rec = packedRecordEnd(data, rec, len(data)) {
+		if data[rec] == '#' || data[rec] == '\n' {
+			continue
+		}
+		recName, err := packedRecordName(data, rec)
+		if err != nil {
+			return Ref{}, false, err
+		}
+		if recName == name {
+			ref, err := parsePackedRecord(data, rec)
+			return ref, err == nil, err
+		}
+	}
+	return Ref{}, false, nil
+}
+
+// packedRecordStart returns the start of the packed-refs record
+// containing the byte at p, which is no earlier than lo
+func packedRecordStart(data []byte, lo, p int) int {
+	for p > lo && (data[p-1] != '\n' || data[p] == '^') {
+		p--
+	}
+	return p
+}
+
+// packedRecordEnd returns the start of the packed-refs record
+// following the record that starts at p, or end
+func packedRecordEnd(data []byte, p, end int) int {
+	for p++; p < end && (data[p-1] != '\n' || data[p] == '^'); p++ {
+	}
+	return p
+}
+
+// packedRecordName returns the name of the ref in the
+// packed-refs record that starts at rec
+func packedRecordName(data []byte, rec int) (string, error) {
+	line := data[rec:]
+	if eol := bytes.IndexByte(line, '\n'); eol >= 0 {
+		line = line[:eol]
+	}
+	sp := bytes.IndexByte(line, ' ')
+	if sp < 0 {
+		return "", fmt.Errorf("malformed packed-refs line: %s", line)
+	}
+	return string(bytes.TrimRight(line[sp+1:], "\r")), nil
+}
+
+// parsePackedRecord parses the packed-refs record that starts at rec
+func parsePackedRecord(data []byte, rec int) (Ref, error) {
+	end := packedRecordEnd(data, rec, len(data))
+	lines := strings.Split(strings.TrimSpace(string(data[rec:end])), "\n")
+	fields := strings.Fields(lines[0])
+	if len(fields) != 2 {
+		return Ref{}, fmt.Errorf("malformed packed-refs line: %s", lines[0])
+	}
+	ref := Ref{Name: fields[1], Target: SHA(fields[0])}
+	if len(lines) > 1 {
+		ref.Peeled = SHA(strings.TrimSpace(lines[1])[1:])
+	}
+	return ref, nil
+}
+
+// resolveRef follows the ref with the given full name
+// (and any symbolic refs) to the object that it points to
+func resolveRef(dir string, name string) (SHA, error) {
+	for depth := 0; depth < maxSymrefDepth; depth++ {
+		target, symref, err := readRef(dir, name)
+		if err != nil {
+			return "", err
+		}
+		if symref == "" {
+			return target, nil
+		}
+		name = symref
+	}
+	return "", fmt.Errorf("symbolic refs nested too deeply: %s", name)
+}
+
+// expandRef returns the full name of the ref that name refers to,
+// using the same rules as git: "master" may refer to refs/heads/master,
+// "origin" may refer to refs/remotes/origin/HEAD, and so on.
+func expandRef(dir string, name string) (string, error) {
+	rules := []string{"%s", "refs/%s", "refs/tags/%s", "refs/heads/%s", "refs/remotes/%s", "refs/remotes/%s/HEAD"}
+	for i, rule := range rules {
+		if i == 0 && !strings.HasPrefix(name, "refs/") && !isPseudoref(name) {
+			// only names like HEAD or ORIG_HEAD live directly in the git directory
+			continue
+		}
+		full := fmt.Sprintf(rule, name)
+		_, _, err := readRef(dir, full)
+		if err == nil {
+			return full, nil
+		}
+		if !errors.Is(err, ErrRefNotFound) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrRefNotFound, name)
+}
+
+// isPseudoref returns true for names such as HEAD and FETCH_HEAD
+func isPseudoref(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'A' || c > 'Z') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// ResolveRef returns the object that the named ref points to,
+// following any symbolic refs. name may be a full ref name,
+// such as "refs/heads/master", or any abbreviation that git accepts,
+// such as "master", "origin/master", or "HEAD".
+func (r *Repository) ResolveRef(name string) (SHA, error) {
+	dir, err := r.gitDir()
+	if err != nil {
+		return "", err
+	}
+	full, err := expandRef(dir, name)
+	if err != nil {
+		return "", err
+	}
+	return resolveRef(dir, full)
+}
+
+// currentBranch returns the full name of the branch that HEAD points to.
+// If HEAD is detached, it returns the empty string.
+func currentBranch(dir string) (string, error) {
+	_, symref, err := readRef(dir, "HEAD")
+	return symref, err
+}
+
+// Upstream returns the full name of the ref that is configured
+// as the upstream of branch (using branch.<name>.remote and
+// branch.<name>.merge). For a remote branch, this is the
+// corresponding remote-tracking ref, such as refs/remotes/origin/master.
+func (r *Repository) Upstream(branch string) (string, error) {
+	branch = strings.TrimPrefix(branch, "refs/heads/")
+	config, err := r.Config()
+	if err != nil {
+		return "", err
+	}
+	remote, ok := config.Get("branch." + branch + ".remote")
+	if !ok {
+		return "", fmt.Errorf("no upstream configured for branch %s", branch)
+	}
+	merge, ok := config.Get("branch." + branch + ".merge")
+	if !ok {
+		return "", fmt.Errorf("no upstream configured for branch %s", branch)
+	}
+	if remote == "." {
+		// the upstream is a local branch
+		return merge, nil
+	}
+
+	for _, refspec := range config.GetAll("remote." + remote + ".fetch") {
+		if dst, ok := mapRefspec(refspec, merge); ok {
+			return dst, nil
+		}
+	}
+	return "", fmt.Errorf("upstream %s of branch %s is not fetched from remote %s", merge, branch, remote)
+}
+
+// mapRefspec maps the ref src to its destination using a fetch refspec,
+// such as +refs/heads/*:refs/remotes/origin/*
+func mapRefspec(refspec string, src string) (string, bool) {
+	refspec = strings.TrimPrefix(refspec, "+")
+	parts := strings.SplitN(refspec, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", false
+	}
+	from, to := parts[0], parts[1]
+
+	i := strings.Index(from, "*")
+	if i < 0 {
+		return to, from == src
+	}
+	prefix, suffix := from[:i], from[i+1:]
+	if !strings.HasPrefix(src, prefix) || !strings.HasSuffix(src, suffix) || len(src) < len(prefix)+len(suffix) {
+		return "", false
+	}
+	matched := src[len(prefix) : len(src)-len(suffix)]
+	return strings.Replace(to, "*", matched, 1), true
+}
+
+// RefsPointingAt returns the names of the refs that point to the object
+// with the given name, either directly or (for annotated tags) after
+// peeling, as in git tag --points-at. The names are sorted.
+func RefsPointingAt(repo *Repository, name SHA) ([]string, error) {
+	name, err := repo.expandSHA(name)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := repo.Refs()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, ref := range refs {
+		peeled := ref.Peeled
+		if peeled == "" && ref.Target != name {
+			// loose refs are not peeled in advance
+			peeled, err = repo.peel(ref.Target)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if ref.Target == name || peeled == name {
+			result = append(result, ref.Name)
+		}
+	}
+	return result, nil
+}
+
+// peel follows an annotated tag (or chain of tags) to the object
+// it points to. If name is not a tag, it returns the empty string.
+func (r *Repository) peel(name SHA) (SHA, error) {
+	var peeled SHA
+	for {
+		obj, err := r.Object(name)
+		if err != nil {
+			return "", err
+		}
+		tag, ok := obj.(Tag)
+		if !ok {
+			return peeled, nil
+		}
+		peeled = tag.Object
+		name = tag.Object
+	}
+}
+
+// lockFile creates the lock file for path, which git uses to
+// guard against concurrent updates. The caller must remove the
+// lock file (or rename it over path) when finished.
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("unable to lock %s: %s.lock already exists", path, path)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// writePackedRefs writes refs to the (already locked) packed-refs file
+// and then commits it by renaming the lock file over packed-refs.
+// refs must already be sorted by name.
+func writePackedRefs(lock *os.File, dir string, refs []Ref) error {
+	w := bufio.NewWriter(lock)
+	w.WriteString(packedRefsHeader)
+	for _, ref := range refs {
+		fmt.Fprintf(w, "%s %s\n", ref.Target, ref.Name)
+		if ref.Peeled != "" {
+			fmt.Fprintf(w, "^%s\n", ref.Peeled)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := lock.Sync(); err != nil {
+		return err
+	}
+	if err := lock.Close(); err != nil {
+		return err
+	}
+	return os.Rename(lock.Name(), filepath.Join(dir, "packed-refs"))
+}
+
+// ValidRefName checks that name is a valid full ref name (such as
+// "refs/heads/master"), following the rules of git check-ref-format.
+// If it is not, the returned error wraps ErrInvalidRefName
+// and describes the problem.
+func ValidRefName(name string) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w %q: %s", ErrInvalidRefName, name, reason)
+	}
+
+	switch {
+	case name == "":
+		return invalid("empty name")
+	case name == "@":
+		return invalid("cannot be @")
+	case !strings.Contains(name, "/"):
+		return invalid("must contain at least one /")
+	case strings.HasSuffix(name, "."):
+		return invalid("cannot end with .")
+	case strings.Contains(name, ".."):
+		return invalid("cannot contain ..")
+	case strings.Contains(name, "@{"):
+		return invalid("cannot contain @{")
+	}
+
+	for _, c := range name {
+		switch {
+		case c < 040 || c == 0177:
+			return invalid("cannot contain control characters")
+		case strings.ContainsRune(" ~^:?*[\\", c):
+			return invalid(fmt.Sprintf("cannot contain %q", c))
+		}
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		switch {
+		case component == "":
+			return invalid("cannot begin or end with /, or contain //")
+		case strings.HasPrefix(component, "."):
+			return invalid("components cannot begin with .")
+		case strings.HasSuffix(component, ".lock"):
+			return invalid("components cannot end with .lock")
+		}
+	}
+	return nil
+}
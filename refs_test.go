@@ -0,0 +1,159 @@
+package gitgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_ValidRefName(t *testing.T) {
+	cases := map[string]bool{
+		"refs/heads/master":          true,
+		"refs/heads/feature/foo-bar": true,
+		"refs/tags/v1.0":             true,
+		"refs/heads/a.b":             true,
+		"refs/heads/@":               true,
+		"refs/heads/日本語":             true,
+
+		"":                       false,
+		"@":                      false,
+		"master":                 false,
+		"/refs/heads/master":     false,
+		"refs/heads/master/":     false,
+		"refs/heads//master":     false,
+		"refs/heads/.hidden":     false,
+		"refs/heads/master.":     false,
+		"refs/heads/a..b":        false,
+		"refs/heads/master.lock": false,
+		"refs/heads/a.lock/b":    false,
+		"refs/heads/a@{1}":       false,
+		"refs/heads/a b":         false,
+		"refs/heads/a~1":         false,
+		"refs/heads/a^":          false,
+		"refs/heads/a:b":         false,
+		"refs/heads/a?":          false,
+		"refs/heads/a*":          false,
+		"refs/heads/a[b":         false,
+		"refs/heads/a\\b":        false,
+		"refs/heads/a\tb":        false,
+		"refs/heads/a\x7fb":      false,
+	}
+
+	for name, valid := range cases {
+		err := ValidRefName(name)
+		if valid && err != nil {
+			t.Errorf("%q: expected valid ref name and received %s", name, err)
+		}
+		if !valid && !errors.Is(err, ErrInvalidRefName) {
+			t.Errorf("%q: expected ErrInvalidRefName and received %v", name, err)
+		}
+	}
+}
+
+func Test_RefsPointingAt(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	cases := map[SHA][]string{
+		"37213e7bb3c334a0f7708c7afcab5babb3f95434": {"refs/heads/master", "refs/remotes/origin/master", "refs/tags/0.1"},
+		// the tag object itself
+		"49bac2b0a923fe6481c7cc207837cf663748c1ed": {"refs/tags/0.1"},
+		"37213e7": {"refs/heads/master", "refs/remotes/origin/master", "refs/tags/0.1"},
+		"fe89ee30bbcdfdf376beae530cc53f967012f31c": nil,
+	}
+	for name, expected := range cases {
+		result, err := RefsPointingAt(repo, name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(expected, result) {
+			t.Errorf("Expected and result don't match for %s:\n%+v\n%+v", name, expected, result)
+		}
+	}
+}
+
+// writeTestPackedRefs writes a sorted packed-refs file with n tags,
+// every other one of which is peeled
+func writeTestPackedRefs(t testing.TB, dir string, n int) {
+	var buf bytes.Buffer
+	buf.WriteString(packedRefsHeader)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, "%040x refs/tags/v%06d\n", i, i)
+		if i%2 == 0 {
+			fmt.Fprintf(&buf, "^%040x\n", i+n)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "packed-refs"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_LookupPackedRef(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const n = 1001
+	writeTestPackedRefs(t, dir, n)
+	for _, i := range []int{0, 1, 2, 499, 500, 999, 1000} {
+		name := fmt.Sprintf("refs/tags/v%06d", i)
+		expected := Ref{Name: name, Target: SHA(fmt.Sprintf("%040x", i))}
+		if i%2 == 0 {
+			expected.Peeled = SHA(fmt.Sprintf("%040x", i+n))
+		}
+		ref, ok, err := lookupPackedRef(dir, name)
+		if err != nil || !ok {
+			t.Errorf("%s: not found (%v)", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(expected, ref) {
+			t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, ref)
+		}
+	}
+	for _, name := range []string{"refs/heads/master", "refs/tags/v", "refs/tags/v001001", "refs/tags/v000500x", "refs/zzz"} {
+		if ref, ok, err := lookupPackedRef(dir, name); ok || err != nil {
+			t.Errorf("%s: expected no ref and received %+v (%v)", name, ref, err)
+		}
+	}
+
+	// the test repository's packed-refs file is not marked as sorted
+	gitDir := filepath.Join("test_data", "dot_git")
+	ref, ok, err := lookupPackedRef(gitDir, "refs/remotes/origin/master")
+	if err != nil || !ok || ref.Target != "fe89ee30bbcdfdf376beae530cc53f967012f31c" {
+		t.Errorf("expected refs/remotes/origin/master and received %+v (%v)", ref, err)
+	}
+}
+
+// BenchmarkPackedRefs compares parsing a packed-refs file with 200,000
+// refs to looking up a single ref in it
+func BenchmarkPackedRefs(b *testing.B) {
+	dir, err := ioutil.TempDir("", "gitgo")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestPackedRefs(b, dir, 200000)
+
+	b.Run("ReadAll", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := readPackedRefs(dir); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Lookup", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, ok, err := lookupPackedRef(dir, "refs/tags/v123456"); !ok || err != nil {
+				b.Fatalf("lookup failed: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,293 @@
+package gitgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrRangeNotSupported is returned by HTTPReaderAt when the server
+// responds to a range request with the entire file
+var ErrRangeNotSupported = errors.New("server does not support range requests")
+
+// HTTPReaderAt is an io.ReaderAt for a file served over HTTP, such as
+// a packfile in a repository served by the dumb HTTP protocol or by a
+// static object store. Each call to ReadAt makes a single HTTP range
+// request, so only the requested bytes are downloaded.
+type HTTPReaderAt struct {
+	// Client is used to make requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+	URL    string
+}
+
+func (h *HTTPReaderAt) client() *http.Client {
+	if h.Client == nil {
+		return http.DefaultClient
+	}
+	return h.Client
+}
+
+// ReadAt reads len(p) bytes starting at offset off
+func (h *HTTPReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	req, err := http.NewRequest("GET", h.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	resp, err := h.client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF
+	case http.StatusOK:
+		return 0, fmt.Errorf("%w: %s", ErrRangeNotSupported, h.URL)
+	default:
+		return 0, fmt.Errorf("unexpected status for %s: %s", h.URL, resp.Status)
+	}
+
+	// the server returns fewer bytes than requested
+	// if the range extends past the end of the file
+	n, err := io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Size returns the size of the file, using a HEAD request
+func (h *HTTPReaderAt) Size() (int64, error) {
+	resp, err := h.client().Head(h.URL)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status for %s: %s", h.URL, resp.Status)
+	}
+	size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Content-Length for %s: %s", h.URL, err)
+	}
+	return size, nil
+}
+
+// RemotePack reads individual objects from a packfile without reading
+// the entire packfile. The index is read in full (it is small compared
+// to the packfile), and is used to find the byte range of each object,
+// which is read on demand.
+type RemotePack struct {
+	pack     io.ReaderAt
+	hashSize int // width of the object names in bytes
+	objects  map[SHA]*packObject
+	names    map[int]SHA // by offset
+
+	// ends maps the offset of each object to the offset where it ends,
+	// which is the offset of the next object (or the trailing checksum)
+	ends map[int]int
+}
+
+// OpenRemotePack reads the index at idxURL, and returns a RemotePack
+// that reads objects from the packfile at packURL using HTTP range
+// requests. If client is nil, http.DefaultClient is used.
+func OpenRemotePack(client *http.Client, packURL, idxURL string) (*RemotePack, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(idxURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status for %s: %s", idxURL, resp.Status)
+	}
+	idx, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	pack := &HTTPReaderAt{Client: client, URL: packURL}
+	size, err := pack.Size()
+	if err != nil {
+		return nil, err
+	}
+	return NewRemotePack(pack, size, bytes.NewReader(idx))
+}
+
+// OpenRemotePacks opens every packfile in the repository at repoURL
+// (the URL of its git directory) that is served by the dumb HTTP protocol.
+// The packfiles are listed by the repository's objects/info/packs file
+// (see UpdateInfoPacks). If client is nil, http.DefaultClient is used.
+func OpenRemotePacks(client *http.Client, repoURL string) ([]*RemotePack, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	objectsURL := strings.TrimSuffix(repoURL, "/") + "/objects"
+	resp, err := client.Get(objectsURL + "/info/packs")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status for %s/info/packs: %s", objectsURL, resp.Status)
+	}
+	names, err := ReadInfoPacks(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	packs := make([]*RemotePack, 0, len(names))
+	for _, name := range names {
+		base := objectsURL + "/pack/" + strings.TrimSuffix(name, ".pack")
+		pack, err := OpenRemotePack(client, base+".pack", base+".idx")
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	return packs, nil
+}
+
+// NewRemotePack returns a RemotePack that reads objects from pack,
+// which is size bytes long, using the corresponding index file
+func NewRemotePack(pack io.ReaderAt, size int64, idx io.Reader) (*RemotePack, error) {
+	objects, hashSize, err := parseIdx(idx, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &RemotePack{
+		pack:     pack,
+		hashSize: hashSize,
+		objects:  map[SHA]*packObject{},
+		names:    map[int]SHA{},
+		ends:     map[int]int{},
+	}
+	offsets := make([]int, 0, len(objects))
+	for _, object := range objects {
+		p.objects[object.Name] = object
+		p.names[object.Offset] = object.Name
+		offsets = append(offsets, object.Offset)
+	}
+	sort.Ints(offsets)
+
+	// the packfile ends with a checksum, which is the same width as the object names
+	end := int(size) - hashSize
+	for i := len(offsets) - 1; i >= 0; i-- {
+		if offsets[i] >= end {
+			return nil, fmt.Errorf("object offset %d is past the end of the packfile", offsets[i])
+		}
+		p.ends[offsets[i]] = end
+		end = offsets[i]
+	}
+	return p, nil
+}
+
+// Object returns the object with the given name. Only the byte ranges
+// of the object (and of its delta bases, if it is stored as a delta)
+// are read from the packfile.
+func (p *RemotePack) Object(name SHA) (GitObject, error) {
+	object, err := p.resolve(name, 0)
+	if err != nil {
+		return nil, err
+	}
+	return object.normalize(os.File{})
+}
+
+// resolve reads the object with the given name, and applies
+// its delta (if any). depth is the length of the delta chain so far.
+func (p *RemotePack) resolve(name SHA, depth int) (*packObject, error) {
+	entry, ok := p.objects[name]
+	if !ok {
+		return nil, fmt.Errorf("%w in packfile: %s", ErrObjectNotFound, name)
+	}
+	// a chain longer than the number of objects must contain a cycle
+	if depth > len(p.objects) {
+		return nil, fmt.Errorf("delta chain for %s is too long", name)
+	}
+
+	data := make([]byte, p.ends[entry.Offset]-entry.Offset)
+	n, err := p.pack.ReadAt(data, int64(entry.Offset))
+	if err != nil && !(err == io.EOF && n == len(data)) {
+		return nil, err
+	}
+
+	// readObjectHeader expects offsets relative to the start of the
+	// packfile, so the object's byte range is presented at its offset
+	window := io.NewSectionReader(offsetReaderAt{int64(entry.Offset), data}, 0, int64(entry.Offset+len(data)))
+	object := &packObject{Name: entry.Name, Offset: entry.Offset}
+	r := errReadSeeker{window, nil}
+	readObjectHeader(&r, object, p.hashSize)
+	if r.err != nil {
+		return nil, fmt.Errorf("error reading header for %s: %s", name, r.err)
+	}
+	if !object._type.known() {
+		return nil, fmt.Errorf("%w %d for %s at offset %d", ErrUnknownObjectType, object._type, object.Name, object.Offset)
+	}
+	object.Data, err = inflateAt(window, object.dataOffset, object.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	if object._type < OBJ_OFS_DELTA {
+		object.BaseObjectType = object._type
+		object.PatchedData = object.Data
+		return object, nil
+	}
+
+	if object._type == OBJ_OFS_DELTA {
+		baseName, ok := p.names[object.baseOffset]
+		if !ok {
+			return nil, fmt.Errorf("could not find object with negative offset %d - %d for %s", object.Offset, object.negativeOffset, object.Name)
+		}
+		object.BaseObjectName = baseName
+	}
+	base, err := p.resolve(object.BaseObjectName, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := patchDelta(bytes.NewReader(base.PatchedData), bytes.NewReader(object.Data))
+	if err != nil {
+		return nil, err
+	}
+	object.PatchedData, err = ioutil.ReadAll(patched)
+	if err != nil {
+		return nil, err
+	}
+	object.BaseObjectType = base.BaseObjectType
+	object.Depth = base.Depth + 1
+	return object, nil
+}
+
+// offsetReaderAt is an io.ReaderAt for data, which is
+// located at the given offset within a larger file
+type offsetReaderAt struct {
+	offset int64
+	data   []byte
+}
+
+func (o offsetReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < o.offset || off >= o.offset+int64(len(o.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, o.data[off-o.offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,89 @@
+package gitgo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func Test_RemotePack(t *testing.T) {
+	packDir := filepath.Join("test_data", "dot_git", "objects", "pack")
+	const fixture = "pack-d310969c4ba0ebfe725685fa577a1eec5ecb15b2"
+
+	// record the range requested for every request for the packfile
+	var mu sync.Mutex
+	var ranges []string
+	files := http.FileServer(http.Dir(packDir))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && filepath.Ext(r.URL.Path) == ".pack" {
+			mu.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			mu.Unlock()
+		}
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	remote, err := OpenRemotePack(nil, server.URL+"/"+fixture+".pack", server.URL+"/"+fixture+".idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pack, err := os.Open(filepath.Join(packDir, fixture+".pack"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pack.Close()
+	idx, err := os.Open(filepath.Join(packDir, fixture+".idx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	objects, err := VerifyPack(pack, idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, object := range objects {
+		expected, err := object.normalize(*RepoDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := remote.Object(object.Name)
+		if err != nil {
+			t.Errorf("%s: %s", object.Name, err)
+			continue
+		}
+		if !reflect.DeepEqual(expected, result) {
+			t.Errorf("Expected and result don't match for %s:\n%+v\n%+v", object.Name, expected, result)
+		}
+	}
+
+	for _, r := range ranges {
+		if r == "" {
+			t.Errorf("the packfile was requested without a range")
+		}
+	}
+
+	if _, err := remote.Object("0000000000000000000000000000000000000000"); err == nil {
+		t.Errorf("expected error for an object that is not in the packfile")
+	}
+}
+
+func Test_HTTPReaderAtNoRanges(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ranges are ignored"))
+	}))
+	defer server.Close()
+
+	r := &HTTPReaderAt{URL: server.URL}
+	_, err := r.ReadAt(make([]byte, 4), 2)
+	if !errors.Is(err, ErrRangeNotSupported) {
+		t.Errorf("expected ErrRangeNotSupported and received %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package gitgo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxReplaceDepth is the number of replacements that will be followed
+// for a single object (as in git)
+const maxReplaceDepth = 5
+
+// ErrReplaceCycle is returned when the replace refs for an object
+// form a loop, so that there is no final replacement
+var ErrReplaceCycle = errors.New("replace refs form a cycle")
+
+// replaceRefs returns the replacements listed in refs/replace,
+// mapping the name of each replaced object to its substitute.
+// They are read the first time they are needed.
+//
+// Replacements are disabled if GIT_NO_REPLACE_OBJECTS is set.
+func (r *Repository) replaceRefs() (map[SHA]SHA, error) {
+	if r.replacements != nil {
+		return r.replacements, nil
+	}
+	replacements := map[SHA]SHA{}
+	if _, ok := os.LookupEnv("GIT_NO_REPLACE_OBJECTS"); !ok {
+		refs, err := r.Refs()
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			if strings.HasPrefix(ref.Name, "refs/replace/") {
+				replacements[SHA(strings.TrimPrefix(ref.Name, "refs/replace/"))] = ref.Target
+			}
+		}
+	}
+	r.replacements = replacements
+	return replacements, nil
+}
+
+// replace returns the object that should be read in place of name.
+// Chains of replacements are followed to the final substitute.
+// If name has not been replaced, it is returned unchanged.
+func (r *Repository) replace(name SHA) (SHA, error) {
+	replacements, err := r.replaceRefs()
+	if err != nil || len(replacements) == 0 {
+		return name, err
+	}
+	original := name
+	if len(name) < 40 {
+		name, err = r.expandSHA(name)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	seen := map[SHA]bool{name: true}
+	for depth := 0; ; depth++ {
+		next, ok := replacements[name]
+		if !ok && depth == 0 {
+			return original, nil
+		}
+		if !ok {
+			return name, nil
+		}
+		if seen[next] {
+			return "", fmt.Errorf("%w: %s", ErrReplaceCycle, next)
+		}
+		if depth == maxReplaceDepth {
+			return "", fmt.Errorf("replace depth too high for object %s", name)
+		}
+		seen[next] = true
+		name = next
+	}
+}
+
+// withName returns obj with its name set to name. Replacement objects
+// are returned under the name of the object that they replace.
+func withName(obj GitObject, name SHA) GitObject {
+	switch o := obj.(type) {
+	case Commit:
+		o.Name = name
+		return o
+	case Tag:
+		o.Name = name
+		return o
+	}
+	return obj
+}
@@ -1,27 +1,51 @@
 package gitgo
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// ErrNotRepository is returned when a git repository cannot be found
+var ErrNotRepository = errors.New("not a git repository")
+
+// ErrObjectNotFound is returned when an object is neither
+// stored as a loose object nor in any packfile
+var ErrObjectNotFound = errors.New("object not found")
+
 type Repository struct {
-	Basedir   os.File
+	Basedir os.File
+
+	// PackConcurrency limits the number of packfiles that are parsed
+	// at the same time, each of which requires open files.
+	// If it is zero, runtime.NumCPU() is used.
+	PackConcurrency int
+
 	packfiles []*packfile
+
+	// the replace refs, keyed by the object being replaced
+	replacements map[SHA]SHA
+
+	// the parents listed in info/grafts, keyed by commit
+	grafts map[SHA][]SHA
 }
 
+// Object returns the object with the given name (or unique prefix).
+// If the object has been replaced (see git replace), the replacement
+// is returned in its place, under the original name. Otherwise, if
+// the object is a commit listed in info/grafts, its parents are
+// replaced by the parents listed there.
 func (r *Repository) Object(input SHA) (obj GitObject, err error) {
 	err = r.normalizeBasename()
-	if r.packfiles == nil {
-		packfiles, err := r.listPackfiles()
-		if err != nil {
-			return nil, err
-		}
-		r.packfiles = packfiles
+	if _, err := r.packs(); err != nil {
+		return nil, err
 	}
 	basedir := &r.Basedir
-	if filepath.Base(basedir.Name()) != ".git" {
+	if filepath.Base(basedir.Name()) != ".git" && !isGitDir(basedir.Name()) {
 		basedirName := basedir.Name()
 		basedir.Close()
 		basedir, err = os.Open(filepath.Join(basedirName, ".git"))
@@ -29,8 +53,285 @@ func (r *Repository) Object(input SHA) (obj GitObject, err error) {
 			return nil, err
 		}
 	}
-	obj, err = newObject(input, basedir, r.packfiles)
-	return obj, err
+	// a linked worktree shares the object store of the main repository
+	common, err := commonDir(basedir.Name())
+	if err != nil {
+		return nil, err
+	}
+	if common != basedir.Name() {
+		basedir, err = os.Open(common)
+		if err != nil {
+			return nil, err
+		}
+		defer basedir.Close()
+	}
+
+	name, err := r.replace(input)
+	if err != nil {
+		return nil, err
+	}
+	obj, err = newObject(name, basedir, r.packfiles)
+	if err != nil {
+		return nil, err
+	}
+	if name == input {
+		return r.graft(obj)
+	}
+
+	original, err := r.expandSHA(input)
+	if err != nil {
+		return nil, err
+	}
+	return withName(obj, original), nil
+}
+
+// ReadObjects reads many objects at once, which is much faster than
+// calling Object for each of them. Loose objects are read directly,
+// and each packfile is searched once for all of the packed objects.
+// The result is keyed by the requested names.
+func (r *Repository) ReadObjects(names []SHA) (map[SHA]GitObject, error) {
+	dir, err := r.commonGitDir()
+	if err != nil {
+		return nil, err
+	}
+	packfiles, err := r.packs()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[SHA]GitObject, len(names))
+
+	// packed objects to look up, mapped to the names they were requested by
+	// (which differ if the object has been replaced)
+	packed := map[SHA][]SHA{}
+	for _, name := range names {
+		if _, ok := result[name]; ok {
+			continue
+		}
+		if len(name) < 40 {
+			// prefixes need to be expanded by searching every pack anyway
+			obj, err := r.Object(name)
+			if err != nil {
+				return nil, err
+			}
+			result[name] = obj
+			continue
+		}
+
+		target, err := r.replace(name)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := objectFromFile(filepath.Join(dir, "objects", string(target[:2]), string(target[2:])), target, r.Basedir)
+		if err == nil {
+			result[name], err = r.named(obj, name, target)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		packed[target] = append(packed[target], name)
+	}
+
+	for _, pack := range packfiles {
+		for target, requested := range packed {
+			p, ok := pack.objects[target]
+			if !ok {
+				continue
+			}
+			obj, err := p.normalize(r.Basedir)
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range requested {
+				result[name], err = r.named(obj, name, target)
+				if err != nil {
+					return nil, err
+				}
+			}
+			delete(packed, target)
+		}
+	}
+	for _, requested := range packed {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, requested[0])
+	}
+	return result, nil
+}
+
+// named returns obj, which was read as target, under the name it was
+// requested by. Grafts only apply to objects that have not been replaced.
+func (r *Repository) named(obj GitObject, name SHA, target SHA) (GitObject, error) {
+	if name == target {
+		return r.graft(obj)
+	}
+	return withName(obj, name), nil
+}
+
+// ObjectSize returns the size of the contents of the object with the
+// given name. Only the object header is read, which is much cheaper
+// than reading the entire object with Object, especially for large blobs.
+func (r *Repository) ObjectSize(name SHA) (int, error) {
+	name, err := r.expandSHA(name)
+	if err != nil {
+		return 0, err
+	}
+	dir, err := r.commonGitDir()
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(filepath.Join(dir, "objects", string(name[:2]), string(name[2:])))
+	if err == nil {
+		defer f.Close()
+		return looseObjectSize(f)
+	}
+	if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	packfiles, err := r.packs()
+	if err != nil {
+		return 0, err
+	}
+	for _, pack := range packfiles {
+		if p, ok := pack.objects[name]; ok {
+			return p.objectSize()
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+}
+
+// HasAll reports which of the given objects exist in the repository,
+// either as loose objects or in a packfile. It is much faster than
+// checking each object individually: each object directory is listed
+// once, and each packfile's index is read once and binary searched,
+// without parsing the packfiles themselves. Names must be unabbreviated,
+// and replacements are not applied.
+func (r *Repository) HasAll(names []SHA) (map[SHA]bool, error) {
+	dir, err := r.commonGitDir()
+	if err != nil {
+		return nil, err
+	}
+	objectsDir := filepath.Join(dir, "objects")
+
+	result := make(map[SHA]bool, len(names))
+	byDir := map[string][]SHA{}
+	for _, name := range names {
+		if !validObjectName(name) {
+			return nil, fmt.Errorf("invalid object name: %s", name)
+		}
+		result[name] = false
+		byDir[string(name[:2])] = append(byDir[string(name[:2])], name)
+	}
+
+	for prefix, dirNames := range byDir {
+		files, err := ioutil.ReadDir(filepath.Join(objectsDir, prefix))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		loose := make(map[string]bool, len(files))
+		for _, file := range files {
+			loose[file.Name()] = true
+		}
+		for _, name := range dirNames {
+			result[name] = loose[string(name[2:])]
+		}
+	}
+
+	var missing [][]byte
+	for name, found := range result {
+		if !found {
+			raw, _ := hex.DecodeString(string(name))
+			missing = append(missing, raw)
+		}
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	packDir := filepath.Join(objectsDir, "pack")
+	files, err := ioutil.ReadDir(packDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".idx" {
+			continue
+		}
+		idx, err := readPackIndex(filepath.Join(packDir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		remaining := missing[:0]
+		for _, raw := range missing {
+			if idx.contains(raw) {
+				result[SHA(hex.EncodeToString(raw))] = true
+				continue
+			}
+			remaining = append(remaining, raw)
+		}
+		missing = remaining
+		if len(missing) == 0 {
+			break
+		}
+	}
+	return result, nil
+}
+
+// rawObject returns the type and the (uncompressed) contents of the
+// object with the given full name, exactly as they are stored.
+// Unlike Object, replacements are not applied.
+func (r *Repository) rawObject(name SHA) (objType string, data []byte, err error) {
+	dir, err := r.commonGitDir()
+	if err != nil {
+		return "", nil, err
+	}
+
+	f, err := os.Open(filepath.Join(dir, "objects", string(name[:2]), string(name[2:])))
+	if err == nil {
+		defer f.Close()
+		zr, err := inflateLooseObject(f)
+		if err != nil {
+			return "", nil, err
+		}
+		objType, _, err := readLooseHeader(zr)
+		if err != nil {
+			return "", nil, err
+		}
+		data, err := ioutil.ReadAll(zr)
+		return objType, data, err
+	}
+	if !os.IsNotExist(err) {
+		return "", nil, err
+	}
+
+	packfiles, err := r.packs()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, pack := range packfiles {
+		if p, ok := pack.objects[name]; ok {
+			data, err := p.contents()
+			return p.Type(), data, err
+		}
+	}
+	return "", nil, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+}
+
+// packs returns the repository's packfiles, which are
+// parsed the first time they are needed
+func (r *Repository) packs() ([]*packfile, error) {
+	if r.packfiles == nil {
+		packfiles, err := r.listPackfiles()
+		if err != nil {
+			return nil, err
+		}
+		r.packfiles = packfiles
+	}
+	return r.packfiles, nil
 }
 
 func (r *Repository) normalizeBasename() error {
@@ -43,7 +344,7 @@ func (r *Repository) normalizeBasename() error {
 		}
 	}
 	candidateName := candidate.Name()
-	if filepath.Base(candidateName) != ".git" {
+	if filepath.Base(candidateName) != ".git" && !isGitDir(candidateName) {
 		candidateName = filepath.Join(candidateName, ".git")
 	}
 	for {
@@ -83,7 +384,7 @@ func findGitDir(pwd *os.File) (dir *os.File, err error) {
 
 	candidate := pwd
 	candidateName := candidate.Name()
-	if filepath.Base(candidateName) != ".git" {
+	if filepath.Base(candidateName) != ".git" && !isGitDir(candidateName) {
 		candidateName = filepath.Join(candidateName, ".git")
 	}
 	for {
@@ -107,3 +408,178 @@ func findGitDir(pwd *os.File) (dir *os.File, err error) {
 	}
 	return nil, fmt.Errorf("could not find the git repository")
 }
+
+// Discover finds the repository that contains startDir, by looking for
+// a .git directory (or a .git file that points to one) in startDir and
+// then in each of its parent directories, as the git command-line tools do.
+// A bare repository is also recognized.
+//
+// The search does not continue into any of the directories listed in
+// GIT_CEILING_DIRECTORIES, and it does not cross a filesystem boundary
+// unless GIT_DISCOVERY_ACROSS_FILESYSTEM is set.
+func Discover(startDir string) (*Repository, error) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	startDevice, checkDevice := deviceID(info)
+	if across, _ := parseBool(os.Getenv("GIT_DISCOVERY_ACROSS_FILESYSTEM")); across {
+		checkDevice = false
+	}
+
+	ceilings := map[string]bool{}
+	for _, ceiling := range filepath.SplitList(os.Getenv("GIT_CEILING_DIRECTORIES")) {
+		// git ignores relative paths
+		if filepath.IsAbs(ceiling) {
+			ceilings[filepath.Clean(ceiling)] = true
+		}
+	}
+
+	for {
+		gitdir, err := gitDirAt(dir)
+		if err != nil {
+			return nil, err
+		}
+		if gitdir != "" {
+			f, err := os.Open(gitdir)
+			if err != nil {
+				return nil, err
+			}
+			return &Repository{Basedir: *f}, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir || ceilings[parent] {
+			break
+		}
+		if checkDevice {
+			info, err := os.Stat(parent)
+			if err != nil {
+				return nil, err
+			}
+			if device, ok := deviceID(info); ok && device != startDevice {
+				break
+			}
+		}
+		dir = parent
+	}
+	return nil, fmt.Errorf("%w (or any of the parent directories): %s", ErrNotRepository, startDir)
+}
+
+// gitDirAt returns the git directory for dir, if dir contains a .git
+// directory or .git file, or if dir is itself a bare repository.
+// Otherwise, it returns the empty string.
+func gitDirAt(dir string) (string, error) {
+	dotgit := filepath.Join(dir, ".git")
+	info, err := os.Stat(dotgit)
+	switch {
+	case err == nil && info.IsDir():
+		if isGitDir(dotgit) {
+			return dotgit, nil
+		}
+	case err == nil:
+		return readGitFile(dotgit)
+	case !os.IsNotExist(err):
+		return "", err
+	}
+
+	if isGitDir(dir) {
+		return dir, nil
+	}
+	return "", nil
+}
+
+// readGitFile reads a .git file, which is used in place of a .git
+// directory by submodules and linked worktrees. It contains the path
+// of the real git directory, relative to the directory containing it:
+// "gitdir: <path>"
+func readGitFile(path string) (string, error) {
+	bts, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	contents := strings.TrimSpace(string(bts))
+	if !strings.HasPrefix(contents, "gitdir:") {
+		return "", fmt.Errorf("invalid gitfile format: %s", path)
+	}
+	gitdir := strings.TrimSpace(strings.TrimPrefix(contents, "gitdir:"))
+	if !filepath.IsAbs(gitdir) {
+		gitdir = filepath.Join(filepath.Dir(path), gitdir)
+	}
+	if !isGitDir(gitdir) {
+		return "", fmt.Errorf("%w: %s", ErrNotRepository, gitdir)
+	}
+	return gitdir, nil
+}
+
+// commonDir returns the directory containing the parts of a repository
+// that are shared between worktrees (such as the config). For a linked
+// worktree, this is named by the "commondir" file in its git directory.
+// Otherwise, it is the git directory itself.
+func commonDir(gitdir string) (string, error) {
+	bts, err := ioutil.ReadFile(filepath.Join(gitdir, "commondir"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return gitdir, nil
+		}
+		return "", err
+	}
+	common := strings.TrimSpace(string(bts))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitdir, common)
+	}
+	return common, nil
+}
+
+// worktreeRoot returns the root of the working tree whose git directory
+// is gitdir. The git directory of a linked worktree records the path of
+// the worktree's .git file in its "gitdir" file. Otherwise, the working
+// tree is the directory that contains the git directory.
+func worktreeRoot(gitdir string) (string, error) {
+	bts, err := ioutil.ReadFile(filepath.Join(gitdir, "gitdir"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return filepath.Dir(gitdir), nil
+		}
+		return "", err
+	}
+	dotgit := strings.TrimSpace(string(bts))
+	if !filepath.IsAbs(dotgit) {
+		dotgit = filepath.Join(gitdir, dotgit)
+	}
+	return filepath.Dir(dotgit), nil
+}
+
+// isPerWorktreeRef returns true if the ref with the given full name
+// is stored in each worktree's own git directory, rather than in the
+// common directory: HEAD and the other pseudorefs, and the refs
+// under refs/bisect/, refs/worktree/, and refs/rewritten/
+func isPerWorktreeRef(name string) bool {
+	if !strings.Contains(name, "/") {
+		return true
+	}
+	for _, prefix := range []string{"refs/bisect/", "refs/worktree/", "refs/rewritten/"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGitDir returns true if path looks like a git directory
+// (one that has a HEAD and an object store)
+func isGitDir(path string) bool {
+	if _, err := os.Stat(filepath.Join(path, "HEAD")); err != nil {
+		return false
+	}
+	for _, name := range []string{"objects", "commondir"} {
+		if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,302 @@
+package gitgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func Test_Discover(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+	gitdir := repo.Basedir.Name()
+	worktree := filepath.Dir(gitdir)
+
+	// make sure that we never find the repository containing
+	// the temporary directory (if there is one)
+	defer os.Setenv("GIT_CEILING_DIRECTORIES", os.Getenv("GIT_CEILING_DIRECTORIES"))
+	os.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(worktree))
+
+	subdir := filepath.Join(worktree, "a", "b")
+	if err := os.MkdirAll(subdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{worktree, subdir, gitdir} {
+		result, err := Discover(dir)
+		if err != nil {
+			t.Errorf("%s: %s", dir, err)
+			continue
+		}
+		if result.Basedir.Name() != gitdir {
+			t.Errorf("%s: expected %s and received %s", dir, gitdir, result.Basedir.Name())
+		}
+	}
+
+	// a .git file pointing to the git directory
+	linked := filepath.Join(worktree, "linked")
+	if err := os.MkdirAll(filepath.Join(linked, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(linked, ".git"), []byte("gitdir: ../.git\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Discover(filepath.Join(linked, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Clean(result.Basedir.Name()) != gitdir {
+		t.Errorf("expected %s and received %s", gitdir, result.Basedir.Name())
+	}
+	if _, err := result.ResolveRef("HEAD"); err != nil {
+		t.Errorf("could not read discovered repository: %s", err)
+	}
+
+	// a linked worktree (as created by git worktree add) has its own git
+	// directory, which shares the objects and refs of the main repository
+	const head = SHA("37213e7bb3c334a0f7708c7afcab5babb3f95434")
+	const bisect = SHA("b499a18d0aea475863ef88dcdb0941ca71a53b13")
+	worktreeGitdir, wt := addTestWorktree(t, repo, "wt")
+	bisectRef := filepath.Join(worktreeGitdir, "refs", "bisect", "bad")
+	if err := os.MkdirAll(filepath.Dir(bisectRef), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(bisectRef, []byte(string(bisect)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = Discover(wt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Basedir.Name() != worktreeGitdir {
+		t.Errorf("expected %s and received %s", worktreeGitdir, result.Basedir.Name())
+	}
+	for rev, expected := range map[string]SHA{"HEAD": head, "master": head, "refs/bisect/bad": bisect} {
+		sha, err := result.RevParse(rev)
+		if err != nil || sha != expected {
+			t.Errorf("%s: expected %s and received %s (%v)", rev, expected, sha, err)
+		}
+	}
+	refs, err := result.Refs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, ref := range refs {
+		names[ref.Name] = true
+	}
+	if !names["refs/heads/master"] || !names["refs/bisect/bad"] {
+		t.Errorf("expected shared and per-worktree refs and received %+v", refs)
+	}
+	if _, err := result.Object(head); err != nil {
+		t.Errorf("could not read object from linked worktree: %s", err)
+	}
+
+	// the search stops before entering a ceiling directory
+	os.Setenv("GIT_CEILING_DIRECTORIES", filepath.Join(worktree, "a")+string(filepath.ListSeparator)+"relative/paths/are/ignored")
+	_, err = Discover(subdir)
+	if !errors.Is(err, ErrNotRepository) {
+		t.Errorf("expected ErrNotRepository and received %v", err)
+	}
+}
+
+func Test_ReplaceRefs(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+	dir := repo.Basedir.Name()
+
+	original := writeTestObject(t, repo, OBJ_BLOB, []byte("original\n"))
+	first := writeTestObject(t, repo, OBJ_BLOB, []byte("first replacement\n"))
+	final := writeTestObject(t, repo, OBJ_BLOB, []byte("final replacement\n"))
+
+	replaceRef := func(name, replacement SHA) {
+		path := filepath.Join(dir, "refs", "replace", string(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(string(replacement)+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	readBlob := func(repo *Repository, name SHA) (string, error) {
+		obj, err := repo.Object(name)
+		if err != nil {
+			return "", err
+		}
+		return string(obj.(Blob).Contents), nil
+	}
+
+	// a two-step replacement resolves to the final object
+	replaceRef(original, first)
+	replaceRef(first, final)
+	for _, name := range []SHA{original, original[:10], first} {
+		contents, err := readBlob(&Repository{Basedir: repo.Basedir}, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contents != "final replacement\n" {
+			t.Errorf("%s: expected the final replacement and received %q", name, contents)
+		}
+	}
+
+	// replacements can be disabled
+	os.Setenv("GIT_NO_REPLACE_OBJECTS", "1")
+	contents, err := readBlob(&Repository{Basedir: repo.Basedir}, original)
+	os.Unsetenv("GIT_NO_REPLACE_OBJECTS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != "original\n" {
+		t.Errorf("expected the original object and received %q", contents)
+	}
+
+	// a loop of replacements is an error
+	replaceRef(final, original)
+	_, err = readBlob(&Repository{Basedir: repo.Basedir}, original)
+	if !errors.Is(err, ErrReplaceCycle) {
+		t.Errorf("expected ErrReplaceCycle and received %v", err)
+	}
+}
+
+func Test_ReadObjects(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	names := []SHA{
+		// loose
+		"37213e7bb3c334a0f7708c7afcab5babb3f95434",
+		"c9b4a98fd0720609e086293d53a28b1ad8d41c55",
+		// packed
+		"fe89ee30bbcdfdf376beae530cc53f967012f31c",
+		"d22fc8a57073fdecae2001d00aff921440d3aabd",
+		"6b32b1ac731898894c403f6b621bdda167ab8d7c",
+		// prefix
+		"3ead3116",
+	}
+	result, err := repo.ReadObjects(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != len(names) {
+		t.Errorf("expected %d objects and received %d", len(names), len(result))
+	}
+	for _, name := range names {
+		expected, err := repo.Object(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, result[name]) {
+			t.Errorf("Expected and result don't match for %s:\n%+v\n%+v", name, expected, result[name])
+		}
+	}
+
+	_, err = repo.ReadObjects([]SHA{names[0], "0000000000000000000000000000000000000000"})
+	if err == nil {
+		t.Errorf("expected error for a missing object")
+	}
+}
+
+// BenchmarkReadObjects reads objects spread across 10 packfiles,
+// with and without parsing the packfiles concurrently
+func BenchmarkReadObjects(b *testing.B) {
+	repo, cleanup := tempRepo(b)
+	defer cleanup()
+	packDir := filepath.Join(repo.Basedir.Name(), "objects", "pack")
+	const fixture = "pack-d310969c4ba0ebfe725685fa577a1eec5ecb15b2"
+
+	// each copy of the fixture pack is parsed independently
+	for i := 0; i < 10; i++ {
+		for _, ext := range []string{".pack", ".idx"} {
+			bts, err := ioutil.ReadFile(filepath.Join(packDir, fixture+ext))
+			if err != nil {
+				b.Fatal(err)
+			}
+			name := fmt.Sprintf("pack-%040d%s", i, ext)
+			if err := ioutil.WriteFile(filepath.Join(packDir, name), bts, 0444); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	names := []SHA{
+		"fe89ee30bbcdfdf376beae530cc53f967012f31c",
+		"d22fc8a57073fdecae2001d00aff921440d3aabd",
+		"6b32b1ac731898894c403f6b621bdda167ab8d7c",
+	}
+
+	for workers := 1; workers <= runtime.NumCPU(); workers *= 2 {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				r := &Repository{Basedir: repo.Basedir, PackConcurrency: workers}
+				if _, err := r.ReadObjects(names); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func Test_HasAll(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	// HasAll only reads the index, so a SHA-256
+	// index can be checked alongside the SHA-1 one
+	const sha256Pack = "pack-0547dfc63c73d6597bb2e233d141bfd853b0b5d19c19819c65cb99bb4bca232e.idx"
+	idx, err := ioutil.ReadFile(filepath.Join("test_data", "sha256", sha256Pack))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(repo.Basedir.Name(), "objects", "pack", sha256Pack), idx, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[SHA]bool{
+		// loose
+		"37213e7bb3c334a0f7708c7afcab5babb3f95434": true,
+		"c9b4a98fd0720609e086293d53a28b1ad8d41c55": true,
+		// packed, including the first and last names in the index
+		"05d3cc770bd3524cc25d47e083d8942ad25033f0": true,
+		"fe89ee30bbcdfdf376beae530cc53f967012f31c": true,
+		"d22fc8a57073fdecae2001d00aff921440d3aabd": true,
+		// missing
+		"0000000000000000000000000000000000000000": false,
+		"37213e7bb3c334a0f7708c7afcab5babb3f95435": false,
+		"fe89ee30bbcdfdf376beae530cc53f967012f31d": false,
+		"ffffffffffffffffffffffffffffffffffffffff": false,
+	}
+	pack, err := os.Open(filepath.Join("test_data", "sha256", strings.TrimSuffix(sha256Pack, ".idx")+".pack"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pack.Close()
+	objects, err := VerifyPack(pack, bytes.NewReader(idx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, object := range objects {
+		expected[object.Name] = true
+	}
+
+	names := make([]SHA, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	result, err := repo.HasAll(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, result)
+	}
+
+	if _, err := repo.HasAll([]SHA{"37213e7b"}); err == nil {
+		t.Errorf("expected error for an abbreviated name")
+	}
+}
@@ -0,0 +1,110 @@
+package gitgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RerereResolution is a conflict resolution recorded by git rerere
+// in the .git/rr-cache directory
+type RerereResolution struct {
+	// ID is the conflict ID, which is the hash of the conflicted hunks
+	ID string
+
+	// Variant distinguishes different conflicts that share the same ID.
+	// The first (and usually only) variant is 0.
+	Variant int
+
+	// Preimage is the conflicted file, including conflict markers
+	Preimage []byte
+
+	// Postimage is the file after the conflict was resolved.
+	// It is nil if the resolution has not been recorded yet.
+	Postimage []byte
+}
+
+// Resolved returns true if a resolution has been recorded for the conflict
+func (r RerereResolution) Resolved() bool {
+	return r.Postimage != nil
+}
+
+// RerereState returns the conflict resolutions recorded by git rerere,
+// sorted by conflict ID. If rerere has never been used in the repository,
+// it returns no resolutions and no error.
+func RerereState(repo *Repository) ([]RerereResolution, error) {
+	dir, err := repo.commonGitDir()
+	if err != nil {
+		return nil, err
+	}
+	cacheDir := filepath.Join(dir, "rr-cache")
+	entries, err := ioutil.ReadDir(cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var result []RerereResolution
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		resolutions, err := readRerereEntry(filepath.Join(cacheDir, entry.Name()), entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, resolutions...)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ID != result[j].ID {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].Variant < result[j].Variant
+	})
+	return result, nil
+}
+
+// readRerereEntry reads all of the variants recorded for a single conflict ID.
+// The first variant is stored in "preimage" and "postimage", and
+// subsequent variants are stored in "preimage.N" and "postimage.N"
+func readRerereEntry(dir string, id string) ([]RerereResolution, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []RerereResolution
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), "preimage") {
+			continue
+		}
+		suffix := strings.TrimPrefix(file.Name(), "preimage")
+
+		var variant int
+		if suffix != "" {
+			variant, err = strconv.Atoi(strings.TrimPrefix(suffix, "."))
+			if err != nil || !strings.HasPrefix(suffix, ".") {
+				// not a file that rerere writes
+				continue
+			}
+		}
+
+		resolution := RerereResolution{ID: id, Variant: variant}
+		resolution.Preimage, err = ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		resolution.Postimage, err = ioutil.ReadFile(filepath.Join(dir, "postimage"+suffix))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		result = append(result, resolution)
+	}
+	return result, nil
+}
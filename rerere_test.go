@@ -0,0 +1,56 @@
+package gitgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_RerereState(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	result, err := RerereState(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 0 {
+		t.Errorf("expected no resolutions and received %d", len(result))
+	}
+
+	const preimage = "<<<<<<<\nfoo\n=======\nbar\n>>>>>>>\n"
+	files := map[string]string{
+		"4b1ee3a3e3a1b2a3c7a7b7f9b6d8e3b3b2a1c0d9/preimage":    preimage,
+		"4b1ee3a3e3a1b2a3c7a7b7f9b6d8e3b3b2a1c0d9/postimage":   "foobar\n",
+		"4b1ee3a3e3a1b2a3c7a7b7f9b6d8e3b3b2a1c0d9/preimage.1":  preimage,
+		"4b1ee3a3e3a1b2a3c7a7b7f9b6d8e3b3b2a1c0d9/thisimage.1": preimage,
+		"0a1b2c3d4e5f60718293a4b5c6d7e8f901234567/preimage":    preimage,
+	}
+	for name, contents := range files {
+		p := filepath.Join(repo.Basedir.Name(), "rr-cache", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []RerereResolution{
+		{ID: "0a1b2c3d4e5f60718293a4b5c6d7e8f901234567", Preimage: []byte(preimage)},
+		{ID: "4b1ee3a3e3a1b2a3c7a7b7f9b6d8e3b3b2a1c0d9", Preimage: []byte(preimage), Postimage: []byte("foobar\n")},
+		{ID: "4b1ee3a3e3a1b2a3c7a7b7f9b6d8e3b3b2a1c0d9", Variant: 1, Preimage: []byte(preimage)},
+	}
+	result, err = RerereState(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, result)
+	}
+	if result[0].Resolved() || !result[1].Resolved() {
+		t.Errorf("incorrect resolution status")
+	}
+}
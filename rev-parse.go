@@ -0,0 +1,220 @@
+package gitgo
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// revParseDateLayouts are the date formats accepted in <refname>@{<date>}.
+// Dates without a time zone are interpreted in the local time zone.
+var revParseDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	RFC2822,
+}
+
+// RevParse resolves a revision to the name of the object it refers to,
+// like git rev-parse. It supports the following syntax from gitrevisions(7):
+//
+//	<sha1>              a full or abbreviated object name
+//	<refname>           a ref name, such as master or origin/master
+//	@                   HEAD
+//	<branch>@{upstream} the upstream of the branch (or @{u});
+//	                    if branch is omitted, the current branch is used
+//	<refname>@{<n>}     the nth prior value of the ref, from the reflog;
+//	                    if refname is omitted, the current branch is used
+//	<refname>@{<date>}  the value of the ref at a prior point in time,
+//	                    from the reflog (see revParseDateLayouts)
+//	:/<text>            the newest commit reachable from HEAD whose message
+//	                    matches the regular expression <text>
+//	:/!-<text>          the newest commit reachable from HEAD whose message
+//	                    does not match <text>
+func (r *Repository) RevParse(rev string) (SHA, error) {
+	dir, err := r.gitDir()
+	if err != nil {
+		return "", err
+	}
+	if rev == "@" {
+		rev = "HEAD"
+	}
+	if strings.HasPrefix(rev, ":/") {
+		return r.revParseMessage(rev[2:])
+	}
+
+	if i := strings.LastIndex(rev, "@{"); i >= 0 && strings.HasSuffix(rev, "}") {
+		return r.revParseAt(dir, rev[:i], rev[i+2:len(rev)-1])
+	}
+
+	sha, err := r.ResolveRef(rev)
+	if err == nil {
+		return sha, nil
+	}
+	if !errors.Is(err, ErrRefNotFound) {
+		return "", err
+	}
+	return r.expandSHA(SHA(rev))
+}
+
+// revParseMessage resolves :/<pattern>, searching the history of HEAD
+// from newest to oldest. As in git, a pattern beginning with "!-" is negated,
+// "!!" escapes a literal "!", and any other pattern beginning with "!"
+// is reserved.
+func (r *Repository) revParseMessage(pattern string) (SHA, error) {
+	var negate bool
+	switch {
+	case strings.HasPrefix(pattern, "!-"):
+		negate = true
+		pattern = pattern[2:]
+	case strings.HasPrefix(pattern, "!!"):
+		pattern = pattern[1:]
+	case strings.HasPrefix(pattern, "!"):
+		return "", fmt.Errorf("unsupported revision: :/%s", pattern)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern in :/%s: %s", pattern, err)
+	}
+
+	head, err := r.ResolveRef("HEAD")
+	if err != nil {
+		return "", err
+	}
+	start, err := r.commit(head)
+	if err != nil {
+		return "", err
+	}
+	seen := map[SHA]bool{start.Name: true}
+	queue := &commitQueue{}
+	heap.Push(queue, start)
+	for queue.Len() > 0 {
+		commit := heap.Pop(queue).(Commit)
+		if re.Match(commit.Message) != negate {
+			return commit.Name, nil
+		}
+		for _, parentName := range commit.Parents {
+			if seen[parentName] {
+				continue
+			}
+			seen[parentName] = true
+			parent, err := r.commit(parentName)
+			if err != nil {
+				return "", err
+			}
+			heap.Push(queue, parent)
+		}
+	}
+	return "", fmt.Errorf("no commit message matches :/%s", pattern)
+}
+
+// revParseAt resolves <ref>@{<spec>}
+func (r *Repository) revParseAt(dir string, ref string, spec string) (SHA, error) {
+	var err error
+	full := ref
+	if ref == "" {
+		full, err = currentBranch(dir)
+		if err != nil {
+			return "", err
+		}
+		if full == "" {
+			return "", fmt.Errorf("HEAD does not point to a branch")
+		}
+	} else {
+		full, err = expandRef(dir, ref)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	switch strings.ToLower(spec) {
+	case "u", "upstream":
+		if !strings.HasPrefix(full, "refs/heads/") {
+			return "", fmt.Errorf("%s is not a branch", ref)
+		}
+		upstream, err := r.Upstream(full)
+		if err != nil {
+			return "", err
+		}
+		return resolveRef(dir, upstream)
+	}
+
+	n, err := strconv.Atoi(spec)
+	if err != nil {
+		for _, layout := range revParseDateLayouts {
+			t, err := time.ParseInLocation(layout, spec, time.Local)
+			if err == nil {
+				return r.RefAtTime(full, t)
+			}
+		}
+	}
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("unsupported revision: %s@{%s}", ref, spec)
+	}
+	entries, err := r.Reflog(full)
+	if err != nil {
+		return "", err
+	}
+	if n >= len(entries) {
+		return "", fmt.Errorf("log for %s only has %d entries", full, len(entries))
+	}
+	return entries[len(entries)-1-n].New, nil
+}
+
+// expandSHA returns the full name of the object with
+// the given (possibly abbreviated) name
+func (r *Repository) expandSHA(prefix SHA) (SHA, error) {
+	prefix = SHA(strings.ToLower(string(prefix)))
+	if len(prefix) < 4 || len(prefix) > 40 || strings.Trim(string(prefix), "0123456789abcdef") != "" {
+		return "", fmt.Errorf("unknown revision: %s", prefix)
+	}
+	if len(prefix) == 40 {
+		return prefix, nil
+	}
+	dir, err := r.commonGitDir()
+	if err != nil {
+		return "", err
+	}
+
+	matches := map[SHA]bool{}
+	files, err := ioutil.ReadDir(filepath.Join(dir, "objects", string(prefix[:2])))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), string(prefix[2:])) {
+			matches[prefix[:2]+SHA(file.Name())] = true
+		}
+	}
+
+	packs, err := r.packs()
+	if err != nil {
+		return "", err
+	}
+	for _, pack := range packs {
+		for name := range pack.objects {
+			if strings.HasPrefix(string(name), string(prefix)) {
+				matches[name] = true
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("unknown revision: %s", prefix)
+	case 1:
+		for name := range matches {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("short object name %s is ambiguous", prefix)
+}
@@ -0,0 +1,83 @@
+package gitgo
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_RevParse(t *testing.T) {
+	repo := &Repository{Basedir: *RepoDir}
+	cases := []struct {
+		rev      string
+		expected SHA
+	}{
+		{"37213e7bb3c334a0f7708c7afcab5babb3f95434", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{"37213e7b", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{"b45377f6", "b45377f6daf59a4cec9e8de64f5df1533a7994cd"},
+		{"HEAD", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{"@", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{"master", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{"refs/heads/master", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{"origin", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{"0.1", "49bac2b0a923fe6481c7cc207837cf663748c1ed"},
+		{"master@{upstream}", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{"@{u}", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{"master@{0}", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{"master@{1}", "b499a18d0aea475863ef88dcdb0941ca71a53b13"},
+		{"@{3}", "fe89ee30bbcdfdf376beae530cc53f967012f31c"},
+		{"HEAD@{2}", "6f63668c72544be8efb5bcf9c6a29676e92de64a"},
+		{"origin/master@{1}", "b499a18d0aea475863ef88dcdb0941ca71a53b13"},
+		{"master@{2015-04-09T18:00:00-04:00}", "6f63668c72544be8efb5bcf9c6a29676e92de64a"},
+		{"master@{2016-01-01}", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{":/Fix", "4bab381d0209b95160f8cc8761fe479ad72187d8"},
+		{":/^Add", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{":/!-^Add", "4bab381d0209b95160f8cc8761fe479ad72187d8"},
+		{":/non-initial commit", "fe89ee30bbcdfdf376beae530cc53f967012f31c"},
+	}
+	for _, c := range cases {
+		result, err := repo.RevParse(c.rev)
+		if err != nil {
+			t.Errorf("%s: %s", c.rev, err)
+			continue
+		}
+		if result != c.expected {
+			t.Errorf("%s: expected %s and received %s", c.rev, c.expected, result)
+		}
+	}
+
+	for _, rev := range []string{"nosuchbranch", "master@{4}", "master@{yesterday}", "master@{2015-01-01}", "0.1@{u}", "zzzz", ":/no such message", ":/!reserved", ":/("} {
+		if result, err := repo.RevParse(rev); err == nil {
+			t.Errorf("%s: expected error and received %s", rev, result)
+		}
+	}
+}
+
+func Test_RefAtTime(t *testing.T) {
+	repo := &Repository{Basedir: *RepoDir}
+	cases := []struct {
+		t        int64
+		expected SHA
+	}{
+		// exactly at the time of the first entry
+		{1428355983, "fe89ee30bbcdfdf376beae530cc53f967012f31c"},
+		{1428355984, "fe89ee30bbcdfdf376beae530cc53f967012f31c"},
+		{1428610355, "6f63668c72544be8efb5bcf9c6a29676e92de64a"},
+		{1428637808, "6f63668c72544be8efb5bcf9c6a29676e92de64a"},
+		{1428951031, "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		{1500000000, "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+	}
+	for _, c := range cases {
+		result, err := repo.RefAtTime("refs/heads/master", time.Unix(c.t, 0))
+		if err != nil {
+			t.Errorf("%d: %s", c.t, err)
+			continue
+		}
+		if result != c.expected {
+			t.Errorf("%d: expected %s and received %s", c.t, c.expected, result)
+		}
+	}
+
+	if _, err := repo.RefAtTime("refs/heads/master", time.Unix(1428355982, 0)); err == nil {
+		t.Errorf("expected error for a time before the first reflog entry")
+	}
+}
@@ -0,0 +1,270 @@
+package gitgo
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sequencer describes a rebase, cherry-pick, or revert that is in progress
+type Sequencer struct {
+	// Operation is "rebase", "cherry-pick", or "revert"
+	Operation string
+
+	// Interactive is true for a rebase started with --interactive
+	Interactive bool
+
+	// HeadName is the branch being rebased (e.g. "refs/heads/master"),
+	// or "detached HEAD"
+	HeadName string
+
+	// Onto is the commit that a rebase is replaying commits onto
+	Onto SHA
+
+	// OrigHead is the commit that HEAD pointed to
+	// when the operation was started
+	OrigHead SHA
+
+	// Done lists the steps that have already been performed,
+	// including the current step
+	Done []TodoItem
+
+	// Todo lists the remaining steps
+	Todo []TodoItem
+}
+
+// A TodoItem is a single step of a rebase or cherry-pick, as
+// listed in a todo file (such as git-rebase-todo)
+type TodoItem struct {
+	// Command is the full name of the command (e.g. "pick"),
+	// even if it was abbreviated in the todo file
+	Command string
+
+	// Commit is the commit that the command applies to, if any.
+	// It is usually abbreviated.
+	Commit SHA
+
+	// Args is the remainder of the line, such as the subject line
+	// of Commit, or the command run by "exec"
+	Args string
+}
+
+// todoCommands maps the abbreviated todo commands to their full names
+var todoCommands = map[string]string{
+	"p": "pick",
+	"r": "reword",
+	"e": "edit",
+	"s": "squash",
+	"f": "fixup",
+	"x": "exec",
+	"b": "break",
+	"d": "drop",
+	"l": "label",
+	"t": "reset",
+	"m": "merge",
+	"u": "update-ref",
+}
+
+// todoCommitCommands are the todo commands that take a commit
+var todoCommitCommands = map[string]bool{
+	"pick":   true,
+	"reword": true,
+	"edit":   true,
+	"squash": true,
+	"fixup":  true,
+	"drop":   true,
+	"revert": true,
+}
+
+// SequencerState returns the rebase, cherry-pick, or revert that is
+// in progress in the repository. If there is none, it returns nil
+// and no error.
+func SequencerState(repo *Repository) (*Sequencer, error) {
+	dir, err := repo.gitDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if isDir(filepath.Join(dir, "rebase-merge")) {
+		return readRebaseMerge(filepath.Join(dir, "rebase-merge"))
+	}
+	if isDir(filepath.Join(dir, "rebase-apply")) {
+		return readRebaseApply(filepath.Join(dir, "rebase-apply"))
+	}
+	if isDir(filepath.Join(dir, "sequencer")) {
+		return readSequencer(dir)
+	}
+	return nil, nil
+}
+
+// readRebaseMerge reads the state of a rebase that uses the
+// merge backend (including all interactive rebases)
+func readRebaseMerge(dir string) (*Sequencer, error) {
+	seq := &Sequencer{Operation: "rebase"}
+	seq.Interactive = fileExists(filepath.Join(dir, "interactive"))
+
+	var err error
+	if seq.HeadName, err = readStateFile(dir, "head-name"); err != nil {
+		return nil, err
+	}
+	onto, err := readStateFile(dir, "onto")
+	if err != nil {
+		return nil, err
+	}
+	seq.Onto = SHA(onto)
+	origHead, err := readStateFile(dir, "orig-head")
+	if err != nil {
+		return nil, err
+	}
+	seq.OrigHead = SHA(origHead)
+
+	if seq.Done, err = readTodoFile(filepath.Join(dir, "done")); err != nil {
+		return nil, err
+	}
+	if seq.Todo, err = readTodoFile(filepath.Join(dir, "git-rebase-todo")); err != nil {
+		return nil, err
+	}
+	return seq, nil
+}
+
+// readRebaseApply reads the state of a rebase that uses the apply
+// backend. Its patches are stored as mailbox files, so there is no todo list.
+func readRebaseApply(dir string) (*Sequencer, error) {
+	seq := &Sequencer{Operation: "rebase"}
+
+	var err error
+	if seq.HeadName, err = readStateFile(dir, "head-name"); err != nil {
+		return nil, err
+	}
+	onto, err := readStateFile(dir, "onto")
+	if err != nil {
+		return nil, err
+	}
+	seq.Onto = SHA(onto)
+	origHead, err := readStateFile(dir, "orig-head")
+	if err != nil {
+		return nil, err
+	}
+	seq.OrigHead = SHA(origHead)
+	return seq, nil
+}
+
+// readSequencer reads the state of a multi-commit cherry-pick or revert
+func readSequencer(gitDir string) (*Sequencer, error) {
+	dir := filepath.Join(gitDir, "sequencer")
+	seq := &Sequencer{Operation: "cherry-pick"}
+
+	head, err := readStateFile(dir, "head")
+	if err != nil {
+		return nil, err
+	}
+	seq.OrigHead = SHA(head)
+
+	if seq.Done, err = readTodoFile(filepath.Join(dir, "done")); err != nil {
+		return nil, err
+	}
+	if seq.Todo, err = readTodoFile(filepath.Join(dir, "todo")); err != nil {
+		return nil, err
+	}
+
+	if fileExists(filepath.Join(gitDir, "REVERT_HEAD")) ||
+		(len(seq.Todo) > 0 && seq.Todo[0].Command == "revert") {
+		seq.Operation = "revert"
+	}
+	return seq, nil
+}
+
+// readStateFile returns the contents of a file in a state directory,
+// without the trailing newline. If the file does not exist,
+// it returns the empty string and no error.
+func readStateFile(dir, name string) (string, error) {
+	bts, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(bts)), nil
+}
+
+// readTodoFile parses a todo list. If the file does not exist,
+// it returns no items and no error.
+func readTodoFile(path string) ([]TodoItem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var items []TodoItem
+	scnr := bufio.NewScanner(f)
+	for scnr.Scan() {
+		line := strings.TrimSpace(scnr.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		item, err := parseTodoLine(line)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, scnr.Err()
+}
+
+// parseTodoLine parses a single line of a todo list, such as
+// "pick 1d833eb Add a feature"
+func parseTodoLine(line string) (TodoItem, error) {
+	fields := strings.SplitN(line, " ", 2)
+	item := TodoItem{Command: fields[0]}
+	if full, ok := todoCommands[item.Command]; ok {
+		item.Command = full
+	}
+	if len(fields) > 1 {
+		item.Args = strings.TrimSpace(fields[1])
+	}
+
+	switch {
+	case todoCommitCommands[item.Command]:
+		// fixup may be given -C or -c to use the message of the commit
+		if item.Command == "fixup" && (strings.HasPrefix(item.Args, "-C ") || strings.HasPrefix(item.Args, "-c ")) {
+			item.Args = strings.TrimSpace(item.Args[3:])
+		}
+	case item.Command == "merge":
+		// merge [-C <commit> | -c <commit>] <label> [# <oneline>]
+		if !strings.HasPrefix(item.Args, "-C ") && !strings.HasPrefix(item.Args, "-c ") {
+			return item, nil
+		}
+		item.Args = strings.TrimSpace(item.Args[3:])
+	default:
+		return item, nil
+	}
+
+	parts := strings.SplitN(item.Args, " ", 2)
+	if parts[0] == "" {
+		return item, fmt.Errorf("missing commit in todo line: %s", line)
+	}
+	item.Commit = SHA(parts[0])
+	item.Args = ""
+	if len(parts) > 1 {
+		item.Args = strings.TrimSpace(parts[1])
+	}
+	return item, nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,100 @@
+package gitgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_SequencerState(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+	dir := repo.Basedir.Name()
+
+	seq, err := SequencerState(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != nil {
+		t.Errorf("expected no operation in progress and received %+v", seq)
+	}
+
+	files := map[string]string{
+		"head-name":   "refs/heads/master\n",
+		"onto":        "fe89ee30bbcdfdf376beae530cc53f967012f31c\n",
+		"orig-head":   "37213e7bb3c334a0f7708c7afcab5babb3f95434\n",
+		"interactive": "",
+		"done":        "pick 6f63b1a Add tests\n",
+		"git-rebase-todo": `r b499fa5 Fix typo
+fixup -C 3721 Update README
+exec go test ./...
+label onto
+merge -C 1d833eb topic # Merge branch 'topic'
+
+# Rebase fe89ee3..37213e7 onto fe89ee3 (3 commands)
+`,
+	}
+	rebaseDir := filepath.Join(dir, "rebase-merge")
+	if err := os.Mkdir(rebaseDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(rebaseDir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := &Sequencer{
+		Operation:   "rebase",
+		Interactive: true,
+		HeadName:    "refs/heads/master",
+		Onto:        "fe89ee30bbcdfdf376beae530cc53f967012f31c",
+		OrigHead:    "37213e7bb3c334a0f7708c7afcab5babb3f95434",
+		Done: []TodoItem{
+			{Command: "pick", Commit: "6f63b1a", Args: "Add tests"},
+		},
+		Todo: []TodoItem{
+			{Command: "reword", Commit: "b499fa5", Args: "Fix typo"},
+			{Command: "fixup", Commit: "3721", Args: "Update README"},
+			{Command: "exec", Args: "go test ./..."},
+			{Command: "label", Args: "onto"},
+			{Command: "merge", Commit: "1d833eb", Args: "topic # Merge branch 'topic'"},
+		},
+	}
+	seq, err = SequencerState(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, seq) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, seq)
+	}
+
+	// a cherry-pick of several commits
+	if err := os.RemoveAll(rebaseDir); err != nil {
+		t.Fatal(err)
+	}
+	sequencerDir := filepath.Join(dir, "sequencer")
+	if err := os.Mkdir(sequencerDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(sequencerDir, "head"), []byte("37213e7bb3c334a0f7708c7afcab5babb3f95434\n"), 0644)
+	ioutil.WriteFile(filepath.Join(sequencerDir, "todo"), []byte("pick 1d833eb Add a feature\npick 3ead311 Add another feature\n"), 0644)
+
+	expected = &Sequencer{
+		Operation: "cherry-pick",
+		OrigHead:  "37213e7bb3c334a0f7708c7afcab5babb3f95434",
+		Todo: []TodoItem{
+			{Command: "pick", Commit: "1d833eb", Args: "Add a feature"},
+			{Command: "pick", Commit: "3ead311", Args: "Add another feature"},
+		},
+	}
+	seq, err = SequencerState(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, seq) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, seq)
+	}
+}
@@ -0,0 +1,96 @@
+package gitgo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ReadInfoPacks parses the objects/info/packs file, which lists the
+// packfiles available to clients of the dumb HTTP protocol. Each packfile
+// is listed on a line of the form "P pack-<sha>.pack". It returns the
+// names of the packfiles (such as "pack-<sha>.pack") in the order listed.
+// Lines of any other type are ignored, as they are by git.
+func ReadInfoPacks(r io.Reader) ([]string, error) {
+	var packs []string
+	scnr := bufio.NewScanner(r)
+	var lineno int
+	for scnr.Scan() {
+		lineno++
+		line := scnr.Text()
+		if !strings.HasPrefix(line, "P ") {
+			continue
+		}
+		name := strings.TrimSpace(line[2:])
+		if !strings.HasPrefix(name, "pack-") || !strings.HasSuffix(name, ".pack") || strings.ContainsAny(name, "/\\") {
+			return nil, fmt.Errorf("bad objects/info/packs line %d: %s", lineno, line)
+		}
+		packs = append(packs, name)
+	}
+	return packs, scnr.Err()
+}
+
+// UpdateInfoPacks writes the objects/info/packs file for the repository,
+// as in git update-server-info, so that the repository can be served
+// by a static web server. Packfiles without a corresponding index
+// are not listed. The most recently modified packfiles are listed first.
+func UpdateInfoPacks(repo *Repository) error {
+	dir, err := repo.commonGitDir()
+	if err != nil {
+		return err
+	}
+	packDir := filepath.Join(dir, "objects", "pack")
+	files, err := ioutil.ReadDir(packDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var packs []os.FileInfo
+	for _, file := range files {
+		base := strings.TrimSuffix(file.Name(), ".pack")
+		if base == file.Name() || !strings.HasPrefix(base, "pack-") {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(packDir, base+".idx")); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		packs = append(packs, file)
+	}
+	sort.SliceStable(packs, func(i, j int) bool {
+		return packs[i].ModTime().After(packs[j].ModTime())
+	})
+
+	infoDir := filepath.Join(dir, "objects", "info")
+	if err := os.MkdirAll(infoDir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(infoDir, "packs")
+	lock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(lock)
+	for _, pack := range packs {
+		fmt.Fprintf(w, "P %s\n", pack.Name())
+	}
+	// git terminates the list with an empty line
+	w.WriteString("\n")
+	err = w.Flush()
+	if closeErr := lock.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(lock.Name())
+		return err
+	}
+	return os.Rename(lock.Name(), path)
+}
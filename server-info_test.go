@@ -0,0 +1,89 @@
+package gitgo
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ReadInfoPacks(t *testing.T) {
+	input := "P pack-d310969c4ba0ebfe725685fa577a1eec5ecb15b2.pack\n" +
+		"P pack-0000000000000000000000000000000000000000.pack\n" +
+		"D ignored\n" +
+		"\n"
+	expected := []string{
+		"pack-d310969c4ba0ebfe725685fa577a1eec5ecb15b2.pack",
+		"pack-0000000000000000000000000000000000000000.pack",
+	}
+	result, err := ReadInfoPacks(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, result)
+	}
+
+	for _, line := range []string{"P ../../config\n", "P pack-abc.idx\n"} {
+		if _, err := ReadInfoPacks(strings.NewReader(line)); err == nil {
+			t.Errorf("expected error for %q", line)
+		}
+	}
+}
+
+func Test_UpdateInfoPacks(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+	dir := repo.Basedir.Name()
+
+	// a packfile without an index is not listed
+	orphan := filepath.Join(dir, "objects", "pack", "pack-0000000000000000000000000000000000000000.pack")
+	if err := ioutil.WriteFile(orphan, []byte("PACK"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateInfoPacks(repo); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(orphan)
+
+	f, err := os.Open(filepath.Join(dir, "objects", "info", "packs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	result, err := ReadInfoPacks(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"pack-d310969c4ba0ebfe725685fa577a1eec5ecb15b2.pack"}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, result)
+	}
+
+	// the packs listed can be read over the dumb HTTP protocol
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+	packs, err := OpenRemotePacks(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packs) != 1 {
+		t.Fatalf("expected 1 packfile and received %d", len(packs))
+	}
+	const name = SHA("fe89ee30bbcdfdf376beae530cc53f967012f31c")
+	expectedObj, err := repo.Object(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := packs[0].Object(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expectedObj, obj) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expectedObj, obj)
+	}
+}
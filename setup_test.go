@@ -1,9 +1,15 @@
 package gitgo
 
 import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path"
+	"path/filepath"
+	"testing"
 )
 
 var RepoDir *os.File
@@ -30,3 +36,115 @@ func init() {
 	}
 
 }
+
+// tempRepo copies the test repository into a temporary directory,
+// so that tests can modify it without affecting the test data.
+// The returned function removes the temporary directory.
+func tempRepo(t testing.TB) (*Repository, func()) {
+	tmp, err := ioutil.TempDir("", "gitgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+
+	src := path.Join("test_data", "dot_git")
+	dst := filepath.Join(tmp, ".git")
+	err = filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		bts, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, bts, info.Mode())
+	})
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	dir, err := os.Open(dst)
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return &Repository{Basedir: *dir}, cleanup
+}
+
+// addTestWorktree adds a linked worktree with the given name to repo,
+// laid out as git worktree add does, with HEAD pointing to master.
+// It returns the worktree's git directory and the root of its working tree.
+func addTestWorktree(t testing.TB, repo *Repository, name string) (gitdir, root string) {
+	common := repo.Basedir.Name()
+	gitdir = filepath.Join(common, "worktrees", name)
+	root = filepath.Join(filepath.Dir(common), name)
+	files := map[string]string{
+		filepath.Join(gitdir, "HEAD"):      "ref: refs/heads/master\n",
+		filepath.Join(gitdir, "commondir"): "../..\n",
+		filepath.Join(gitdir, "gitdir"):    filepath.Join(root, ".git") + "\n",
+		filepath.Join(root, ".git"):        "gitdir: " + gitdir + "\n",
+	}
+	for path, contents := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return gitdir, root
+}
+
+// writeTestObject writes an object to the repository's object store
+func writeTestObject(t testing.TB, repo *Repository, objType packObjectType, data []byte) SHA {
+	name, err := WriteLooseObjectStream(repo.Basedir.Name(), objType, int64(len(data)), bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// testTreeEntry is an entry for writeTestTree
+type testTreeEntry struct {
+	mode string
+	name string
+	hash SHA
+}
+
+// writeTestTree writes a tree object containing the given entries,
+// which must already be sorted in git's tree order
+func writeTestTree(t testing.TB, repo *Repository, entries ...testTreeEntry) SHA {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		raw, err := hex.DecodeString(string(entry.hash))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&buf, "%s %s\x00", entry.mode, entry.name)
+		buf.Write(raw)
+	}
+	return writeTestObject(t, repo, OBJ_TREE, buf.Bytes())
+}
+
+// writeTestCommit writes a commit object with the given tree and parents.
+// The author and committer dates are set to timestamp.
+func writeTestCommit(t testing.TB, repo *Repository, tree SHA, timestamp int64, message string, parents ...SHA) SHA {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "tree %s\n", tree)
+	for _, parent := range parents {
+		fmt.Fprintf(&buf, "parent %s\n", parent)
+	}
+	fmt.Fprintf(&buf, "author gitgo <gitgo@example.com> %d +0000\n", timestamp)
+	fmt.Fprintf(&buf, "committer gitgo <gitgo@example.com> %d +0000\n", timestamp)
+	fmt.Fprintf(&buf, "\n%s\n", message)
+	return writeTestObject(t, repo, OBJ_COMMIT, buf.Bytes())
+}
@@ -0,0 +1,148 @@
+package gitgo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// SpillFile is the backing store used by VerifyPackSpill.
+// An *os.File opened for reading and writing satisfies this interface.
+type SpillFile interface {
+	io.Writer
+	io.ReaderAt
+}
+
+// spillStore appends resolved objects to a SpillFile
+type spillStore struct {
+	f    SpillFile
+	size int64
+}
+
+func (s *spillStore) write(data []byte) (*spillHandle, error) {
+	n, err := s.f.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	h := &spillHandle{r: s.f, offset: s.size, size: int64(n)}
+	s.size += int64(n)
+	return h, nil
+}
+
+// spillHandle refers to the contents of a single object in a spill file
+type spillHandle struct {
+	r      io.ReaderAt
+	offset int64
+	size   int64
+}
+
+func (h *spillHandle) reader() io.Reader {
+	return io.NewSectionReader(h.r, h.offset, h.size)
+}
+
+// VerifyPackSpill is like VerifyPack, but it bounds memory usage by
+// writing each resolved object to spill (usually a temporary file) instead
+// of holding all of the objects in memory. The returned objects do not
+// have Data or PatchedData set. Instead, Reader() reads their contents
+// back from spill on demand, so spill must not be closed until the caller
+// is finished with the objects.
+//
+// This is considerably slower than VerifyPack, because every object is
+// inflated from the packfile only when it is needed, and delta bases are
+// read back from disk (possibly more than once) rather than from memory.
+// In exchange, memory use is proportional to the largest object in the
+// pack rather than to the size of the entire pack, which allows indexing
+// multi-gigabyte packs in constrained environments.
+func VerifyPackSpill(pack io.ReadSeeker, idx io.Reader, spill SpillFile) ([]*packObject, error) {
+	objects, err := parsePack(errReadSeeker{pack, nil}, idx, false, false)
+	if err != nil {
+		return objects, err
+	}
+
+	byName := map[SHA]*packObject{}
+	byOffset := map[int]*packObject{}
+	for _, object := range objects {
+		byName[object.Name] = object
+		byOffset[object.Offset] = object
+	}
+
+	store := &spillStore{f: spill}
+	for _, object := range objects {
+		if object.err != nil {
+			continue
+		}
+		object.err = object.spillPatch(pack, byName, byOffset, store)
+	}
+	return objects, nil
+}
+
+// spillPatch is like Patch, but the patched data is written to store
+// and base objects are read back from store
+func (p *packObject) spillPatch(pack io.ReadSeeker, byName map[SHA]*packObject, byOffset map[int]*packObject, store *spillStore) error {
+	if p.spill != nil {
+		return nil
+	}
+
+	data, err := inflateAt(pack, p.dataOffset, p.Size)
+	if err != nil {
+		return err
+	}
+
+	if p._type < OBJ_OFS_DELTA {
+		p.BaseObjectType = p._type
+	} else {
+		if p._type == OBJ_OFS_DELTA {
+			base, ok := byOffset[p.baseOffset]
+			if !ok {
+				return fmt.Errorf("could not find object with negative offset %d - %d for %s", p.Offset, p.negativeOffset, p.Name)
+			}
+			p.BaseObjectName = base.Name
+		}
+		base, ok := byName[p.BaseObjectName]
+		if !ok {
+			return fmt.Errorf("base object not in dictionary: %s", p.BaseObjectName)
+		}
+		if err := base.spillPatch(pack, byName, byOffset, store); err != nil {
+			return err
+		}
+
+		baseData, err := ioutil.ReadAll(base.Reader())
+		if err != nil {
+			return err
+		}
+		patched, err := patchDelta(bytes.NewReader(baseData), bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		data, err = ioutil.ReadAll(patched)
+		if err != nil {
+			return err
+		}
+		p.BaseObjectType = base.BaseObjectType
+		p.Depth += base.Depth
+	}
+
+	p.spill, err = store.write(data)
+	return err
+}
+
+// inflateAt reads the size bytes of zlib-compressed data that begin at offset
+func inflateAt(r io.ReadSeeker, offset int, size int) ([]byte, error) {
+	if _, err := r.Seek(int64(offset), os.SEEK_SET); err != nil {
+		return nil, err
+	}
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return nil, fmt.Errorf("error reading object at offset %d: %s", offset, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,98 @@
+package gitgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A StashEntry is a single entry in the stash (stash@{N}).
+//
+// Each stash is a merge commit recording the working tree, whose
+// parents are the commit that HEAD pointed to, a commit recording the
+// index, and (if untracked files were stashed) a commit recording
+// the untracked files.
+type StashEntry struct {
+	// Index is N in stash@{N}, where stash@{0} is the most recent stash
+	Index int
+
+	// Message describes the stash, such as "WIP on master: 37213e7 Add tests"
+	Message string
+	Date    time.Time
+
+	// Commit is the stash commit, which records the working tree
+	Commit SHA
+
+	// Base is the commit that HEAD pointed to when the stash was made
+	Base SHA
+
+	// IndexCommit records the contents of the index
+	IndexCommit SHA
+
+	// Untracked records the untracked files, if any were stashed.
+	// Otherwise, it is empty.
+	Untracked SHA
+}
+
+// StashList returns the entries in the stash, most recent first
+// (as in git stash list). If there are no stashes, it returns
+// no entries and no error.
+func StashList(repo *Repository) ([]StashEntry, error) {
+	top, err := repo.ResolveRef("refs/stash")
+	if err != nil {
+		if errors.Is(err, ErrRefNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	reflog, err := repo.Reflog("refs/stash")
+	if err != nil && !errors.Is(err, ErrReflogNotFound) {
+		return nil, err
+	}
+	if len(reflog) == 0 {
+		// without a reflog, only the most recent stash is known
+		reflog = []ReflogEntry{{New: top}}
+	}
+
+	result := make([]StashEntry, 0, len(reflog))
+	for i := len(reflog) - 1; i >= 0; i-- {
+		entry, err := readStashEntry(repo, reflog[i])
+		if err != nil {
+			return nil, err
+		}
+		entry.Index = len(result)
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+func readStashEntry(repo *Repository, logEntry ReflogEntry) (StashEntry, error) {
+	commit, err := repo.commit(logEntry.New)
+	if err != nil {
+		return StashEntry{}, err
+	}
+	if len(commit.Parents) < 2 {
+		return StashEntry{}, fmt.Errorf("%s is not a stash commit", logEntry.New)
+	}
+
+	entry := StashEntry{
+		Message:     logEntry.Message,
+		Date:        logEntry.Date,
+		Commit:      commit.Name,
+		Base:        commit.Parents[0],
+		IndexCommit: commit.Parents[1],
+	}
+	if len(commit.Parents) > 2 {
+		entry.Untracked = commit.Parents[2]
+	}
+
+	// the commit message is the same as the reflog message,
+	// so it can be used if there is no reflog
+	if entry.Message == "" {
+		entry.Message = string(bytes.SplitN(commit.Message, []byte("\n"), 2)[0])
+		entry.Date = commit.CommitterDate
+	}
+	return entry, nil
+}
@@ -0,0 +1,99 @@
+package gitgo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_StashList(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+	dir := repo.Basedir.Name()
+
+	result, err := StashList(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 0 {
+		t.Errorf("expected no stashes and received %+v", result)
+	}
+
+	const head = SHA("37213e7bb3c334a0f7708c7afcab5babb3f95434")
+	tree := SHA("d22fc8a57073fdecae2001d00aff921440d3aabd")
+	index1 := writeTestCommit(t, repo, tree, 1500000000, "index on master: 37213e7 Add tests", head)
+	stash1 := writeTestCommit(t, repo, tree, 1500000000, "WIP on master: 37213e7 Add tests", head, index1)
+	index2 := writeTestCommit(t, repo, tree, 1500000100, "index on master: 37213e7 Add tests", head)
+	untracked := writeTestCommit(t, repo, tree, 1500000100, "untracked files on master: 37213e7 Add tests")
+	stash2 := writeTestCommit(t, repo, tree, 1500000100, "On master: second", head, index2, untracked)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "refs", "stash"), []byte(string(stash2)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// without a reflog, only the top stash is listed
+	date := time.Unix(1500000100, 0)
+	expected := []StashEntry{
+		{Index: 0, Message: "On master: second", Date: date, Commit: stash2, Base: head, IndexCommit: index2, Untracked: untracked},
+	}
+	result, err = StashList(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareStashes(t, expected, result)
+
+	reflog := fmt.Sprintf("%s %s gitgo <gitgo@example.com> 1500000000 +0000\tWIP on master: 37213e7 Add tests\n", SHA("0000000000000000000000000000000000000000"), stash1) +
+		fmt.Sprintf("%s %s gitgo <gitgo@example.com> 1500000100 +0000\tOn master: second\n", stash1, stash2)
+	if err := os.MkdirAll(filepath.Join(dir, "logs", "refs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "logs", "refs", "stash"), []byte(reflog), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = append(expected, StashEntry{
+		Index:       1,
+		Message:     "WIP on master: 37213e7 Add tests",
+		Date:        time.Unix(1500000000, 0),
+		Commit:      stash1,
+		Base:        head,
+		IndexCommit: index1,
+	})
+	result, err = StashList(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareStashes(t, expected, result)
+
+	// a reflog that cannot be parsed is an error, rather than
+	// falling back to the top stash
+	if err := ioutil.WriteFile(filepath.Join(dir, "logs", "refs", "stash"), []byte("not a reflog\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StashList(repo); err == nil {
+		t.Errorf("expected error for a malformed reflog")
+	}
+}
+
+// compareStashes compares stash entries, ignoring the
+// time zone representation of their dates
+func compareStashes(t *testing.T, expected, result []StashEntry) {
+	if len(expected) != len(result) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, result)
+		return
+	}
+	for i := range expected {
+		e, r := expected[i], result[i]
+		if !e.Date.Equal(r.Date) {
+			t.Errorf("stash@{%d}: expected date %s and received %s", i, e.Date, r.Date)
+		}
+		e.Date, r.Date = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(e, r) {
+			t.Errorf("Expected and result don't match:\n%+v\n%+v", e, r)
+		}
+	}
+}
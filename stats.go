@@ -0,0 +1,327 @@
+package gitgo
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// DuplicateBlobs reports blobs whose contents appear at more than one path,
+// such as vendored copies of the same file. It maps the name of each
+// duplicated blob to the (sorted) paths where it appears.
+//
+// If commits are provided, only the trees of those commits are scanned.
+// Otherwise, the tips of all branches are scanned.
+func DuplicateBlobs(repo *Repository, commits ...SHA) (map[SHA][]string, error) {
+	if len(commits) == 0 {
+		var err error
+		commits, err = branchTips(repo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	paths := map[SHA]map[string]bool{}
+	seen := map[SHA]bool{}
+	for _, commit := range commits {
+		// branches often point to the same commit, so
+		// there's no need to walk it more than once
+		if seen[commit] {
+			continue
+		}
+		seen[commit] = true
+
+		tree, err := repo.treeOf(commit)
+		if err != nil {
+			return nil, err
+		}
+
+		err = repo.walkTree(tree, "", func(path string, blob objectMeta) error {
+			if paths[blob.Hash] == nil {
+				paths[blob.Hash] = map[string]bool{}
+			}
+			paths[blob.Hash][path] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := map[SHA][]string{}
+	for name, blobPaths := range paths {
+		if len(blobPaths) < 2 {
+			continue
+		}
+		for p := range blobPaths {
+			result[name] = append(result[name], p)
+		}
+		sort.Strings(result[name])
+	}
+	return result, nil
+}
+
+// A BigBlob is a blob reported by FindLargeBlobs
+type BigBlob struct {
+	Name SHA
+	Size int
+
+	// Paths lists every path where the blob appears (sorted)
+	Paths []string
+
+	// Commits lists every commit whose tree contains the blob
+	Commits []SHA
+}
+
+// FindLargeBlobs reports every blob reachable from the repository's refs
+// that is larger than threshold bytes, along with the paths and commits
+// where it appears. The results are sorted from largest to smallest.
+//
+// Only the size of each blob is read (see Repository.ObjectSize),
+// so blob contents are never inflated.
+func FindLargeBlobs(repo *Repository, threshold int) ([]BigBlob, error) {
+	refs, err := repo.Refs()
+	if err != nil {
+		return nil, err
+	}
+	var pending []SHA
+	for _, ref := range refs {
+		pending = append(pending, ref.Target)
+	}
+
+	finder := largeBlobFinder{
+		repo:      repo,
+		threshold: threshold,
+		sizes:     map[SHA]int{},
+		trees:     map[SHA][]largeBlob{},
+	}
+	blobs := map[SHA]*BigBlob{}
+	paths := map[SHA]map[string]bool{}
+	seen := map[SHA]bool{}
+	for len(pending) > 0 {
+		name := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		obj, err := repo.Object(name)
+		if err != nil {
+			return nil, err
+		}
+		var commit Commit
+		switch obj := obj.(type) {
+		case Tag:
+			pending = append(pending, obj.Object)
+			continue
+		case Commit:
+			commit = obj
+		default:
+			// refs to trees and blobs are unusual, and are not scanned
+			continue
+		}
+		pending = append(pending, commit.Parents...)
+
+		found, err := finder.find(SHA(commit.Tree))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range found {
+			blob := blobs[entry.name]
+			if blob == nil {
+				blob = &BigBlob{Name: entry.name, Size: entry.size}
+				blobs[entry.name] = blob
+				paths[entry.name] = map[string]bool{}
+			}
+			paths[entry.name][entry.path] = true
+
+			// the blob may appear at several paths in the same commit
+			if len(blob.Commits) == 0 || blob.Commits[len(blob.Commits)-1] != commit.Name {
+				blob.Commits = append(blob.Commits, commit.Name)
+			}
+		}
+	}
+
+	result := make([]BigBlob, 0, len(blobs))
+	for name, blob := range blobs {
+		for p := range paths[name] {
+			blob.Paths = append(blob.Paths, p)
+		}
+		sort.Strings(blob.Paths)
+		result = append(result, *blob)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Size != result[j].Size {
+			return result[i].Size > result[j].Size
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// largeBlob is a blob found by largeBlobFinder, with its path
+// relative to the tree that contains it
+type largeBlob struct {
+	path string
+	name SHA
+	size int
+}
+
+// largeBlobFinder finds the large blobs in a tree and its subtrees.
+// Consecutive commits usually share most of their subtrees, so the
+// results for each tree (and the size of each blob) are cached.
+type largeBlobFinder struct {
+	repo      *Repository
+	threshold int
+	sizes     map[SHA]int
+	trees     map[SHA][]largeBlob
+}
+
+func (f *largeBlobFinder) find(name SHA) ([]largeBlob, error) {
+	if found, ok := f.trees[name]; ok {
+		return found, nil
+	}
+	tree, err := f.repo.tree(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []largeBlob
+	for _, entry := range tree.Blobs {
+		size, ok := f.sizes[entry.Hash]
+		if !ok {
+			size, err = f.repo.ObjectSize(entry.Hash)
+			if err != nil {
+				return nil, err
+			}
+			f.sizes[entry.Hash] = size
+		}
+		if size > f.threshold {
+			found = append(found, largeBlob{entry.filename, entry.Hash, size})
+		}
+	}
+	for _, entry := range tree.Trees {
+		subtree, err := f.find(entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, blob := range subtree {
+			blob.path = path.Join(entry.filename, blob.path)
+			found = append(found, blob)
+		}
+	}
+	f.trees[name] = found
+	return found, nil
+}
+
+// TreeStats returns the number of files in tree and its subtrees, and
+// the total size of their contents. Only the size of each blob is read
+// (see Repository.ObjectSize). Submodules (gitlinks) are not included.
+func TreeStats(repo *Repository, tree Tree) (fileCount int, totalBytes int64, err error) {
+	err = repo.walkTree(tree, "", func(path string, blob objectMeta) error {
+		size, err := repo.ObjectSize(blob.Hash)
+		if err != nil {
+			return err
+		}
+		fileCount++
+		totalBytes += int64(size)
+		return nil
+	})
+	return fileCount, totalBytes, err
+}
+
+// branchTips returns the commits that each branch points to
+func branchTips(repo *Repository) ([]SHA, error) {
+	refs, err := repo.Refs()
+	if err != nil {
+		return nil, err
+	}
+	var tips []SHA
+	for _, ref := range refs {
+		if strings.HasPrefix(ref.Name, "refs/heads/") {
+			tips = append(tips, ref.Target)
+		}
+	}
+	return tips, nil
+}
+
+// NewBlobsInCommit returns the blobs in the tree of commit that are not
+// in the tree of any of its parents, which is the content introduced by
+// the commit. A blob that was only moved or copied from another path is
+// not new. The result is sorted, and is empty (but not nil) if the commit
+// introduces no new content. For a root commit, every blob is new.
+func NewBlobsInCommit(repo *Repository, commit SHA) ([]SHA, error) {
+	c, err := repo.commit(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	// everything reachable from the parents' trees. Each parent's tree
+	// is walked in full (rather than only where it differs from the
+	// commit's tree), since a blob that was moved or copied may come
+	// from any path in the parent, including an unchanged subtree
+	old := map[SHA]bool{}
+	for _, parent := range c.Parents {
+		p, err := repo.commit(parent)
+		if err != nil {
+			return nil, err
+		}
+		if err := markTreeContents(repo, SHA(p.Tree), old); err != nil {
+			return nil, err
+		}
+	}
+
+	// a subtree of the commit that is shared with a parent cannot
+	// contain anything new, so only the subtrees that differ are walked
+	result := []SHA{}
+	seen := map[SHA]bool{}
+	pending := []SHA{SHA(c.Tree)}
+	for len(pending) > 0 {
+		name := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if old[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		tree, err := repo.tree(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range tree.Blobs {
+			if !old[entry.Hash] && !seen[entry.Hash] {
+				seen[entry.Hash] = true
+				result = append(result, entry.Hash)
+			}
+		}
+		for _, entry := range tree.Trees {
+			pending = append(pending, entry.Hash)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result, nil
+}
+
+// markTreeContents marks the tree with the given name,
+// along with every blob and subtree that it contains.
+// Trees that are already marked are not walked again.
+func markTreeContents(repo *Repository, name SHA, marked map[SHA]bool) error {
+	if marked[name] {
+		return nil
+	}
+	marked[name] = true
+	tree, err := repo.tree(name)
+	if err != nil {
+		return err
+	}
+	for _, entry := range tree.Blobs {
+		marked[entry.Hash] = true
+	}
+	for _, entry := range tree.Trees {
+		if err := markTreeContents(repo, entry.Hash, marked); err != nil {
+			return err
+		}
+	}
+	return nil
+}
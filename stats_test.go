@@ -0,0 +1,210 @@
+package gitgo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_DuplicateBlobs(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	// there are no duplicates in the test repository
+	result, err := DuplicateBlobs(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 0 {
+		t.Errorf("expected no duplicates and received %+v", result)
+	}
+
+	license := writeTestObject(t, repo, OBJ_BLOB, []byte("license text\n"))
+	readme := writeTestObject(t, repo, OBJ_BLOB, []byte("readme\n"))
+	vendor := writeTestTree(t, repo,
+		testTreeEntry{"100644", "LICENSE", license},
+		testTreeEntry{"100644", "README", readme},
+	)
+	root := writeTestTree(t, repo,
+		testTreeEntry{"100644", "LICENSE", license},
+		testTreeEntry{"100644", "README", readme},
+		testTreeEntry{"100644", "README.copy", readme},
+		testTreeEntry{"40000", "vendor", vendor},
+	)
+	commit := writeTestCommit(t, repo, root, 1500000000, "Vendor a dependency")
+
+	expected := map[SHA][]string{
+		license: {"LICENSE", "vendor/LICENSE"},
+		readme:  {"README", "README.copy", "vendor/README"},
+	}
+	result, err = DuplicateBlobs(repo, commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, result)
+	}
+}
+
+func Test_FindLargeBlobs(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	big := writeTestObject(t, repo, OBJ_BLOB, bytes.Repeat([]byte("0123456789\n"), 100000))
+	small := writeTestObject(t, repo, OBJ_BLOB, []byte("small\n"))
+	dir := writeTestTree(t, repo,
+		testTreeEntry{"100644", "big.bin", big},
+	)
+	root := writeTestTree(t, repo,
+		testTreeEntry{"100644", "big.bin", big},
+		testTreeEntry{"40000", "dir", dir},
+		testTreeEntry{"100644", "small", small},
+	)
+	commit := writeTestCommit(t, repo, root, 1500000000, "Add a large file", "37213e7bb3c334a0f7708c7afcab5babb3f95434")
+	refPath := filepath.Join(repo.Basedir.Name(), "refs", "heads", "large")
+	if err := ioutil.WriteFile(refPath, []byte(string(commit)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []BigBlob{
+		{
+			Name:    "c9b4a98fd0720609e086293d53a28b1ad8d41c55",
+			Size:    2336760,
+			Paths:   []string{"gitgo/gitgo"},
+			Commits: []SHA{"37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		},
+		{
+			Name:    big,
+			Size:    1100000,
+			Paths:   []string{"big.bin", "dir/big.bin"},
+			Commits: []SHA{commit},
+		},
+	}
+	result, err := FindLargeBlobs(repo, 1000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, result)
+	}
+}
+
+func Test_ObjectSize(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	cases := map[SHA]int{
+		// loose
+		"c9b4a98fd0720609e086293d53a28b1ad8d41c55": 2336760,
+		// packed
+		"6b32b1ac731898894c403f6b621bdda167ab8d7c": 1645,
+		"fe89ee30bbcdfdf376beae530cc53f967012f31c": 267,
+	}
+	for name, expected := range cases {
+		size, err := repo.ObjectSize(name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if size != expected {
+			t.Errorf("%s: expected %d and received %d", name, expected, size)
+		}
+	}
+}
+
+func Test_TreeStats(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	a := writeTestObject(t, repo, OBJ_BLOB, []byte("aaaa\n"))
+	b := writeTestObject(t, repo, OBJ_BLOB, []byte("bb\n"))
+	sub := writeTestTree(t, repo,
+		testTreeEntry{"100644", "a", a},
+		testTreeEntry{"100755", "b", b},
+	)
+	root := writeTestTree(t, repo,
+		testTreeEntry{"100644", "a", a},
+		testTreeEntry{"160000", "module", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		testTreeEntry{"40000", "sub", sub},
+	)
+	tree, err := repo.tree(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileCount, totalBytes, err := TreeStats(repo, tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileCount != 3 || totalBytes != 13 {
+		t.Errorf("expected 3 files and 13 bytes and received %d files and %d bytes", fileCount, totalBytes)
+	}
+}
+
+func Test_NewBlobsInCommit(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	blob := func(contents string) SHA {
+		return writeTestObject(t, repo, OBJ_BLOB, []byte(contents))
+	}
+	a, b, c, d, e := blob("a\n"), blob("b\n"), blob("c\n"), blob("d\n"), blob("e\n")
+	sortSHAs := func(names ...SHA) []SHA {
+		sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+		return names
+	}
+
+	// a root commit introduces everything
+	root := writeTestCommit(t, repo, writeTestTree(t, repo,
+		testTreeEntry{"100644", "a", a},
+		testTreeEntry{"40000", "sub", writeTestTree(t, repo, testTreeEntry{"100644", "b", b})},
+	), 1500000000, "root")
+
+	// moving b is not new content, but c is
+	moved := writeTestCommit(t, repo, writeTestTree(t, repo,
+		testTreeEntry{"100644", "a", a},
+		testTreeEntry{"100644", "b", b},
+		testTreeEntry{"40000", "sub", writeTestTree(t, repo, testTreeEntry{"100644", "c", c})},
+	), 1500000001, "move b and add c", root)
+
+	// a merge only introduces what neither parent has
+	side := writeTestCommit(t, repo, writeTestTree(t, repo,
+		testTreeEntry{"100644", "a", a},
+		testTreeEntry{"100644", "d", d},
+	), 1500000002, "add d", root)
+	merge := writeTestCommit(t, repo, writeTestTree(t, repo,
+		testTreeEntry{"100644", "a", a},
+		testTreeEntry{"100644", "b", b},
+		testTreeEntry{"100644", "d", d},
+		testTreeEntry{"100644", "e", e},
+		testTreeEntry{"40000", "sub", writeTestTree(t, repo, testTreeEntry{"100644", "c", c})},
+	), 1500000003, "merge", moved, side)
+
+	// a commit that changes nothing
+	noop := writeTestCommit(t, repo, writeTestTree(t, repo,
+		testTreeEntry{"100644", "a", a},
+		testTreeEntry{"100644", "d", d},
+	), 1500000004, "no-op", side)
+
+	cases := []struct {
+		commit   SHA
+		expected []SHA
+	}{
+		{root, sortSHAs(a, b)},
+		{moved, []SHA{c}},
+		{merge, []SHA{e}},
+		{noop, []SHA{}},
+	}
+	for _, tc := range cases {
+		result, err := NewBlobsInCommit(repo, tc.commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tc.expected, result) {
+			t.Errorf("Expected and result don't match for %s:\n%+v\n%+v", tc.commit, tc.expected, result)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package gitgo
+
+import (
+	"path"
+	"strings"
+)
+
+// treeOf returns the root tree of the commit with the given name.
+// If name refers to an annotated tag, the tag is peeled first.
+func (r *Repository) treeOf(name SHA) (Tree, error) {
+	obj, err := r.Object(name)
+	if err != nil {
+		return Tree{}, err
+	}
+	for {
+		tag, ok := obj.(Tag)
+		if !ok {
+			break
+		}
+		obj, err = r.Object(tag.Object)
+		if err != nil {
+			return Tree{}, err
+		}
+	}
+
+	commit, ok := obj.(Commit)
+	if !ok {
+		return Tree{}, unexpectedType(name, obj, "commit")
+	}
+	return r.tree(SHA(commit.Tree))
+}
+
+// tree returns the tree object with the given name
+func (r *Repository) tree(name SHA) (Tree, error) {
+	obj, err := r.Object(name)
+	if err != nil {
+		return Tree{}, err
+	}
+	tree, ok := obj.(Tree)
+	if !ok {
+		return Tree{}, unexpectedType(name, obj, "tree")
+	}
+	return tree, nil
+}
+
+// walkTree calls fn for every blob in tree and its subtrees, along
+// with the blob's path (relative to the root of tree, prefixed by prefix).
+func (r *Repository) walkTree(tree Tree, prefix string, fn func(path string, blob objectMeta) error) error {
+	for _, blob := range tree.Blobs {
+		if err := fn(path.Join(prefix, blob.filename), blob); err != nil {
+			return err
+		}
+	}
+	for _, entry := range tree.Trees {
+		subtree, err := r.tree(entry.Hash)
+		if err != nil {
+			return err
+		}
+		if err := r.walkTree(subtree, path.Join(prefix, entry.filename), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupPath returns the entry (blob, tree, or gitlink) at the given
+// slash-separated path, relative to the root of tree. If there is no
+// such entry, ok is false.
+func (r *Repository) lookupPath(tree Tree, p string) (entry objectMeta, ok bool, err error) {
+	parts := strings.Split(strings.Trim(path.Clean("/"+p), "/"), "/")
+	for i, part := range parts {
+		var subtree *objectMeta
+		for j := range tree.Trees {
+			if tree.Trees[j].filename == part {
+				subtree = &tree.Trees[j]
+				break
+			}
+		}
+
+		if i == len(parts)-1 {
+			if subtree != nil {
+				return *subtree, true, nil
+			}
+			for _, entries := range [][]objectMeta{tree.Blobs, tree.Gitlinks} {
+				for _, e := range entries {
+					if e.filename == part {
+						return e, true, nil
+					}
+				}
+			}
+			return objectMeta{}, false, nil
+		}
+
+		if subtree == nil {
+			return objectMeta{}, false, nil
+		}
+		tree, err = r.tree(subtree.Hash)
+		if err != nil {
+			return objectMeta{}, false, err
+		}
+	}
+	return objectMeta{}, false, nil
+}
+
+// FlattenTree returns the path (relative to the root of tree) of every
+// blob in tree and its subtrees, mapped to the blob's name. Submodules
+// (gitlinks) are included, mapped to the name of their commit.
+// Subtrees are not included.
+func FlattenTree(repo *Repository, tree Tree) (map[string]SHA, error) {
+	f := treeFlattener{repo: repo, trees: map[SHA]map[string]SHA{}}
+	return f.flatten(tree)
+}
+
+// treeFlattener flattens trees and their subtrees.
+// Identical subtrees (such as vendored copies of the same
+// directory) are flattened once, and cached by name.
+type treeFlattener struct {
+	repo  *Repository
+	trees map[SHA]map[string]SHA
+}
+
+func (f *treeFlattener) flatten(tree Tree) (map[string]SHA, error) {
+	result := map[string]SHA{}
+	for _, entries := range [][]objectMeta{tree.Blobs, tree.Gitlinks} {
+		for _, entry := range entries {
+			result[entry.filename] = entry.Hash
+		}
+	}
+	for _, entry := range tree.Trees {
+		flat, ok := f.trees[entry.Hash]
+		if !ok {
+			subtree, err := f.repo.tree(entry.Hash)
+			if err != nil {
+				return nil, err
+			}
+			flat, err = f.flatten(subtree)
+			if err != nil {
+				return nil, err
+			}
+			f.trees[entry.Hash] = flat
+		}
+		for p, name := range flat {
+			result[path.Join(entry.filename, p)] = name
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package gitgo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_FlattenTree(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	a := writeTestObject(t, repo, OBJ_BLOB, []byte("aaaa\n"))
+	b := writeTestObject(t, repo, OBJ_BLOB, []byte("bb\n"))
+	sub := writeTestTree(t, repo,
+		testTreeEntry{"100644", "a", a},
+		testTreeEntry{"120000", "link", b},
+	)
+	root := writeTestTree(t, repo,
+		testTreeEntry{"100644", "a", a},
+		testTreeEntry{"160000", "module", "37213e7bb3c334a0f7708c7afcab5babb3f95434"},
+		testTreeEntry{"40000", "sub", sub},
+		testTreeEntry{"40000", "vendor", sub},
+	)
+	tree, err := repo.tree(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]SHA{
+		"a":           a,
+		"module":      "37213e7bb3c334a0f7708c7afcab5babb3f95434",
+		"sub/a":       a,
+		"sub/link":    b,
+		"vendor/a":    a,
+		"vendor/link": b,
+	}
+	result, err := FlattenTree(repo, tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected and result don't match:\n%+v\n%+v", expected, result)
+	}
+}
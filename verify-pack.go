@@ -4,6 +4,7 @@ import (
 	"compress/zlib"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"reflect"
 )
@@ -31,9 +32,29 @@ func (er *errReadSeeker) Seek(offset int64, whence int) (int64, error) {
 // VerifyPack returns the pack objects contained in the packfile and
 // corresponding index file.
 func VerifyPack(pack io.ReadSeeker, idx io.Reader) ([]*packObject, error) {
+	return VerifyPackCached(pack, idx, nil)
+}
+
+// VerifyPackCached is like VerifyPack, but delta bases are looked up
+// in cache before the packfile, and the bases resolved from the packfile
+// are added to cache. This allows deltas whose bases are not in the
+// packfile (as in a thin pack) to be resolved, and avoids resolving the
+// same base repeatedly when indexing related packs.
+func VerifyPackCached(pack io.ReadSeeker, idx io.Reader, cache *DeltaBaseCache) ([]*packObject, error) {
+	return verifyPack(pack, idx, cache, false)
+}
+
+// VerifyPackLenient is like VerifyPack, but an object with an unknown
+// type does not cause the entire packfile to be rejected. Instead, the
+// error is recorded on the object, and the rest of the objects are read.
+func VerifyPackLenient(pack io.ReadSeeker, idx io.Reader) ([]*packObject, error) {
+	return verifyPack(pack, idx, nil, true)
+}
+
+func verifyPack(pack io.ReadSeeker, idx io.Reader, cache *DeltaBaseCache, lenient bool) ([]*packObject, error) {
 
 	objectsMap := map[SHA]*packObject{}
-	objects, err := parsePack(errReadSeeker{pack, nil}, idx)
+	objects, err := parsePack(errReadSeeker{pack, nil}, idx, true, lenient)
 	for _, object := range objects {
 		objectsMap[object.Name] = object
 	}
@@ -61,13 +82,15 @@ func VerifyPack(pack io.ReadSeeker, idx io.Reader) ([]*packObject, error) {
 	}
 
 	for _, object := range objectsMap {
-
-		object.err = object.Patch(objectsMap)
+		if object.err != nil {
+			continue
+		}
+		object.err = object.patch(objectsMap, cache)
 	}
 	return objects, err
 }
 
-func parsePack(pack errReadSeeker, idx io.Reader) (objects []*packObject, err error) {
+func parsePack(pack errReadSeeker, idx io.Reader, inflate bool, lenient bool) (objects []*packObject, err error) {
 	signature := make([]byte, 4)
 	pack.read(signature)
 	if string(signature) != "PACK" {
@@ -84,11 +107,12 @@ func parsePack(pack errReadSeeker, idx io.Reader) (objects []*packObject, err er
 	switch v {
 	case 2:
 		// Parse version 2 packfile
-		objects, err = parseIdx(idx, 2)
+		var hashSize int
+		objects, hashSize, err = parseIdx(idx, 2)
 		if err != nil {
 			return
 		}
-		objects, err = parsePackV2(pack, objects)
+		objects, err = parsePackV2(pack, objects, hashSize, inflate, lenient)
 		return
 
 	default:
@@ -105,8 +129,12 @@ func bytesToNum(b []byte) uint {
 }
 
 // parsePackV2 parses a packfile that uses
-// version 2 of the format
-func parsePackV2(r errReadSeeker, objects []*packObject) ([]*packObject, error) {
+// version 2 of the format. hashSize is the width of the object
+// names in bytes, as recorded by the index. If inflate is false, only the object
+// headers are read, and the object data is left in the packfile.
+// If lenient is true, objects of unknown types are returned with
+// an error, rather than causing parsePackV2 to fail.
+func parsePackV2(r errReadSeeker, objects []*packObject, hashSize int, inflate bool, lenient bool) ([]*packObject, error) {
 
 	numObjectsBts := make([]byte, 4)
 	r.read(numObjectsBts)
@@ -115,48 +143,20 @@ func parsePackV2(r errReadSeeker, objects []*packObject) ([]*packObject, error)
 	}
 
 	for _, object := range objects {
-
-		var btsread int
-		r.Seek(int64(object.Offset), os.SEEK_SET)
-		_bytes := make([]byte, 1)
-		btsread += r.read(_bytes)
-		_byte := _bytes[0]
-
-		// This will extract the last three bits of
-		// the first nibble in the byte
-		// which tells us the object type
-		object._type = packObjectType(((_byte >> 4) & 7))
-
-		// determine the (decompressed) object size
-		// and then deflate the following bytes
-
-		// The most-significant byte (MSB)
-		// tells us whether we need to read more bytes
-		// to get the encoded object size
-		MSB := (_byte & 128) // will be either 128 or 0
-
-		// This will extract the last four bits of the byte
-		var objectSize = int((uint(_byte) & 15))
-
-		// shift the first size by 0
-		// and the rest by 4 + (i-1) * 7
-		var shift uint = 4
-
-		// If the most-significant bit is 0, this is the last byte
-		// for the object size
-		for MSB > 0 {
-			// Keep reading the size until the MSB is 0
-			_bytes := make([]byte, 1)
-			btsread += r.read(_bytes)
-			_byte := _bytes[0]
-
-			MSB = (_byte & 128)
-
-			objectSize += int((uint(_byte) & 127) << shift)
-			shift += 7
+		readObjectHeader(&r, object, hashSize)
+		if !object._type.known() && r.err == nil {
+			err := fmt.Errorf("%w %d for %s at offset %d", ErrUnknownObjectType, object._type, object.Name, object.Offset)
+			if !lenient {
+				return nil, err
+			}
+			object.err = err
+			continue
+		}
+		if !inflate {
+			continue
 		}
 
-		object.Size = objectSize
+		objectSize := object.Size
 		switch {
 		case object._type < 5:
 			// the object is a commit, tree, blob, or tag
@@ -185,44 +185,6 @@ func parsePackV2(r errReadSeeker, objects []*packObject) ([]*packObject, error)
 			// TODO figure out why sometimes n < objectSize
 
 		case object._type == OBJ_OFS_DELTA:
-			// read the n-byte offset
-			// from the git docs:
-			// "n bytes with MSB set in all but the last one.
-			// The offset is then the number constructed by
-			// concatenating the lower 7 bit of each byte, and
-			// for n >= 2 adding 2^7 + 2^14 + ... + 2^(7*(n-1))
-			// to the result."
-
-			var offset int
-
-			// number of bytes read in variable length encoding
-			var nbytes uint
-
-			MSB := 128
-			for (MSB & 128) > 0 {
-				nbytes++
-
-				// Keep reading the size until the MSB is 0
-				_bytes := make([]byte, 1)
-				r.read(_bytes)
-				_byte := _bytes[0]
-
-				sevenBytes := uint(_byte) & 127
-
-				offset = (offset << 7) + int(sevenBytes)
-
-				MSB = int(_byte & 128)
-				if MSB == 0 {
-					break
-				}
-			}
-
-			if nbytes >= 2 {
-				offset += (1 << (7 * (nbytes - 1)))
-			}
-
-			object.negativeOffset = offset
-			object.baseOffset = object.Offset - object.negativeOffset
 			object.Data = make([]byte, objectSize)
 
 			zr, err := zlib.NewReader(r.r)
@@ -242,11 +204,6 @@ func parsePackV2(r errReadSeeker, objects []*packObject) ([]*packObject, error)
 			}
 
 		case object._type == OBJ_REF_DELTA:
-			r.Seek(int64(object.Offset), os.SEEK_SET)
-			// Read the 20-byte base object name
-			baseObjName := make([]byte, 20)
-
-			r.read(baseObjName)
 			object.Data = make([]byte, objectSize)
 
 			zr, err := zlib.NewReader(r.r)
@@ -267,9 +224,111 @@ func parsePackV2(r errReadSeeker, objects []*packObject) ([]*packObject, error)
 	return objects, r.err
 }
 
-func parseIdx(idx io.Reader, version int) (objects []*packObject, err error) {
+// readObjectHeader reads the header of the object at object.Offset:
+// the object type and (decompressed) size, followed by the base
+// object offset or name for deltified objects. Afterwards, r is
+// positioned at the start of the object's compressed data.
+// hashSize is the width of a base object name in bytes
+// (20 for SHA-1 and 32 for SHA-256).
+func readObjectHeader(r *errReadSeeker, object *packObject, hashSize int) {
+	r.Seek(int64(object.Offset), os.SEEK_SET)
+	_bytes := make([]byte, 1)
+	r.read(_bytes)
+	_byte := _bytes[0]
+
+	// This will extract the last three bits of
+	// the first nibble in the byte
+	// which tells us the object type
+	object._type = packObjectType(((_byte >> 4) & 7))
+
+	// determine the (decompressed) object size
+	// and then deflate the following bytes
+
+	// The most-significant byte (MSB)
+	// tells us whether we need to read more bytes
+	// to get the encoded object size
+	MSB := (_byte & 128) // will be either 128 or 0
+
+	// This will extract the last four bits of the byte
+	var objectSize = int((uint(_byte) & 15))
+
+	// shift the first size by 0
+	// and the rest by 4 + (i-1) * 7
+	var shift uint = 4
+
+	// If the most-significant bit is 0, this is the last byte
+	// for the object size
+	for MSB > 0 {
+		// Keep reading the size until the MSB is 0
+		_bytes := make([]byte, 1)
+		r.read(_bytes)
+		_byte := _bytes[0]
+
+		MSB = (_byte & 128)
+
+		objectSize += int((uint(_byte) & 127) << shift)
+		shift += 7
+	}
+	object.Size = objectSize
+
+	switch object._type {
+	case OBJ_OFS_DELTA:
+		// read the n-byte offset
+		// from the git docs:
+		// "n bytes with MSB set in all but the last one.
+		// The offset is then the number constructed by
+		// concatenating the lower 7 bit of each byte, and
+		// for n >= 2 adding 2^7 + 2^14 + ... + 2^(7*(n-1))
+		// to the result."
+
+		var offset int
+
+		// number of bytes read in variable length encoding
+		var nbytes uint
+
+		MSB := 128
+		for (MSB & 128) > 0 {
+			nbytes++
+
+			// Keep reading the size until the MSB is 0
+			_bytes := make([]byte, 1)
+			r.read(_bytes)
+			_byte := _bytes[0]
+
+			sevenBytes := uint(_byte) & 127
+
+			offset = (offset << 7) + int(sevenBytes)
+
+			MSB = int(_byte & 128)
+			if MSB == 0 {
+				break
+			}
+		}
+
+		if nbytes >= 2 {
+			offset += (1 << (7 * (nbytes - 1)))
+		}
+
+		object.negativeOffset = offset
+		object.baseOffset = object.Offset - object.negativeOffset
+
+	case OBJ_REF_DELTA:
+		// Read the base object name
+		baseObjName := make([]byte, hashSize)
+		r.read(baseObjName)
+		object.BaseObjectName = SHA(fmt.Sprintf("%x", baseObjName))
+	}
+
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	object.dataOffset = int(pos)
+}
+
+func parseIdx(idx io.Reader, version int) (objects []*packObject, hashSize int, err error) {
 	if version != 2 {
-		return nil, fmt.Errorf("cannot parse IDX with version %d", version)
+		return nil, 0, fmt.Errorf("cannot parse IDX with version %d", version)
 	}
 	// parse version 2 idxfile
 
@@ -277,18 +336,18 @@ func parseIdx(idx io.Reader, version int) (objects []*packObject, err error) {
 	header := make([]byte, 4)
 	n, err := idx.Read(header)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if !reflect.DeepEqual([]byte{255, 116, 79, 99}, header) {
-		return nil, fmt.Errorf("invalid IDX header: %q", string(header))
+		return nil, 0, fmt.Errorf("invalid IDX header: %q", string(header))
 	}
 
 	// Then the version number in four bytes
 	versionBts := make([]byte, 4)
 	_, err = idx.Read(versionBts)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	// We already know the version, so we can ignore it
 
@@ -300,7 +359,7 @@ func parseIdx(idx io.Reader, version int) (objects []*packObject, err error) {
 		err = fmt.Errorf("read incomplete fanout table: %d", n)
 	}
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Initialize the flat fanout table
@@ -313,41 +372,40 @@ func parseIdx(idx io.Reader, version int) (objects []*packObject, err error) {
 	numObjects := int(bytesToNum(fanoutTable[len(fanoutTable)-1]))
 	objects = make([]*packObject, numObjects)
 
+	// The rest of the index depends on the width of the object names,
+	// which is 20 bytes for SHA-1 and 32 bytes for SHA-256
+	rest, err := ioutil.ReadAll(idx)
+	if err != nil {
+		return nil, 0, err
+	}
+	hashSize, err = idxHashSize(rest, numObjects)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	objectNames := make([]SHA, numObjects)
 
 	for i := 0; i < numObjects; i++ {
-		sha := make([]byte, 20)
-		n, err = idx.Read(sha)
-		if err != nil {
-			return nil, err
-		}
-
-		objectNames[i] = SHA(fmt.Sprintf("%x", sha[:n]))
-		objects[i] = &packObject{Name: SHA(fmt.Sprintf("%x", sha[:n]))}
+		sha := rest[i*hashSize : (i+1)*hashSize]
+		objectNames[i] = SHA(fmt.Sprintf("%x", sha))
+		objects[i] = &packObject{Name: objectNames[i]}
 	}
+	rest = rest[numObjects*hashSize:]
 
-	// Then come 4-byte CRC32 values
-	crc32Table := make([]byte, numObjects*4)
-	_, err = idx.Read(crc32Table)
-	if err != nil {
-		return nil, err
-	}
+	// Then come 4-byte CRC32 values, which we skip
+	rest = rest[numObjects*4:]
 
 	// Next come 4-byte offset values
 	// If the MSB is set, there is an index into the next table
 	// otherwise, these are 31 bits each
-	offsetsFlat := make([]byte, numObjects*4)
-	_, err = idx.Read(offsetsFlat)
-	if err != nil {
-		return nil, err
-	}
+	offsetsFlat := rest[:numObjects*4]
 
 	offsets := make([]int, numObjects)
 	for i := 0; i < len(offsets); i++ {
 		offset := int(bytesToNum(offsetsFlat[i*4 : (i+1)*4]))
 		// check if the MSB is 1
 		if offset&2147483648 > 0 {
-			return nil, fmt.Errorf("packfile is too large to parse")
+			return nil, 0, fmt.Errorf("packfile is too large to parse")
 		}
 		offsets[i] = offset
 		objects[i].Offset = offset
@@ -356,23 +414,34 @@ func parseIdx(idx io.Reader, version int) (objects []*packObject, err error) {
 	// If the pack file is more than 2 GB, there will be a table of 8-byte offset entries here
 	// TODO implement this
 
-	// This is the same as the checksum at the end of the corresponding packfile
-	packfileChecksum := make([]byte, 20)
-	_, err = idx.Read(packfileChecksum)
-	if err != nil {
-		return
-	}
+	// The remainder is the checksum of the corresponding packfile,
+	// followed by the checksum of all of the above data.
+	// We're not checking them now.
 
-	// This is the checksum of all of the above data
-	// We're not checking it now, but if we can't read it properly
-	// that means an error has occurred earlier in parsing
-	idxChecksum := make([]byte, 20)
-	_, err = idx.Read(idxChecksum)
-	if err != nil {
-		return
-	}
-
-	// TODO check that there isn't any data left
+	return objects, hashSize, nil
+}
 
-	return objects, err
+// idxHashSize determines the width of the object names in a version 2
+// index, given the contents of the index after the fanout table.
+// After the object names, there are two 4-byte entries (CRC32 and offset)
+// per object, an 8-byte entry for each offset with its MSB set,
+// and two checksums, which are the same width as the object names.
+func idxHashSize(rest []byte, numObjects int) (int, error) {
+	for _, hashSize := range []int{20, 32} {
+		offsetsStart := numObjects*hashSize + numObjects*4
+		offsetsEnd := offsetsStart + numObjects*4
+		if offsetsEnd > len(rest) {
+			continue
+		}
+		var largeOffsets int
+		for i := offsetsStart; i < offsetsEnd; i += 4 {
+			if rest[i]&128 > 0 {
+				largeOffsets++
+			}
+		}
+		if offsetsEnd+largeOffsets*8+2*hashSize == len(rest) {
+			return hashSize, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid IDX size for %d objects: %d", numObjects, len(rest))
 }
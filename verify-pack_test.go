@@ -1,7 +1,11 @@
 package gitgo
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path"
@@ -130,6 +134,72 @@ chain length = 2: 1 object
 	*/
 }
 
+func Test_VerifyPackSpill(t *testing.T) {
+	packFile, err := os.Open(path.Join(RepoDir.Name(), "objects/pack/pack-d310969c4ba0ebfe725685fa577a1eec5ecb15b2.pack"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer packFile.Close()
+
+	idxFile, err := os.Open(path.Join(RepoDir.Name(), "objects/pack/pack-d310969c4ba0ebfe725685fa577a1eec5ecb15b2.idx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idxFile.Close()
+
+	spill, err := ioutil.TempFile("", "gitgo-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(spill.Name())
+	defer spill.Close()
+
+	spilled, err := VerifyPackSpill(packFile, idxFile, spill)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packFile.Seek(0, io.SeekStart)
+	idxFile.Seek(0, io.SeekStart)
+	objects, err := VerifyPack(packFile, idxFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != len(spilled) {
+		t.Fatalf("Read incorrect number of objects: %d, want %d", len(spilled), len(objects))
+	}
+
+	for i, object := range spilled {
+		expected := objects[i]
+		if object.err != nil {
+			t.Errorf("error reading object %s: %s", object.Name, object.err)
+			continue
+		}
+		if object.PatchedData != nil || object.Data != nil {
+			t.Errorf("expected %s to be spilled to disk", object.Name)
+		}
+		if object.Type() != expected.Type() {
+			t.Errorf("Expected type %s and received %s (%s)", expected.Type(), object.Type(), object.Name)
+		}
+		contents, err := ioutil.ReadAll(object.Reader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(contents, expected.PatchedData) {
+			t.Errorf("contents of %s do not match", object.Name)
+		}
+	}
+
+	// spilled objects are read back when they are normalized
+	obj, err := spilled[0].normalize(*RepoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Type() != objects[0].Type() {
+		t.Errorf("expected %s and received %s", objects[0].Type(), obj.Type())
+	}
+}
+
 func BenchmarkVerifyPack(b *testing.B) {
 	packFile, err := os.Open(path.Join(RepoDir.Name(), "objects/pack/pack-d310969c4ba0ebfe725685fa577a1eec5ecb15b2.pack"))
 	if err != nil {
@@ -153,3 +223,88 @@ func BenchmarkVerifyPack(b *testing.B) {
 		idxFile.Seek(0, io.SeekStart)
 	}
 }
+
+func Test_VerifyPackSHA256(t *testing.T) {
+	// test_data/sha256 contains a packfile from a repository using
+	// --object-format=sha256, with one REF_DELTA object
+	const name = "test_data/sha256/pack-0547dfc63c73d6597bb2e233d141bfd853b0b5d19c19819c65cb99bb4bca232e"
+	packFile, err := os.Open(name + ".pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer packFile.Close()
+	idxFile, err := os.Open(name + ".idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idxFile.Close()
+
+	objects, err := VerifyPack(packFile, idxFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 7 {
+		t.Errorf("expected 7 objects and received %d", len(objects))
+	}
+	byName := map[SHA]*packObject{}
+	for _, object := range objects {
+		if object.err != nil {
+			t.Errorf("%s: %s", object.Name, object.err)
+		}
+		if len(object.Name) != 64 {
+			t.Errorf("expected 64-character name and received %s", object.Name)
+		}
+		byName[object.Name] = object
+	}
+
+	var numbers bytes.Buffer
+	for i := 1; i <= 300; i++ {
+		fmt.Fprintf(&numbers, "%d\n", i)
+	}
+	delta := byName["ba73bf0f2f5cc620026fd8fb03e81ac89aeb8991727a160097f1fa97bd70e63b"]
+	if delta == nil {
+		t.Fatal("deltified object not found")
+	}
+	if delta._type != OBJ_REF_DELTA || delta.BaseObjectName != "8a1447130d33486bbb5bd24bc05eb00d3aa92045bbd677ff1babfd064d4c0a07" {
+		t.Errorf("expected REF_DELTA against 8a14471 and received %s against %s", delta._type, delta.BaseObjectName)
+	}
+	if delta.PatchedType() != OBJ_BLOB || !bytes.Equal(delta.PatchedData, numbers.Bytes()) {
+		t.Errorf("expected blob %q and received %s %q", numbers.Bytes(), delta.PatchedType(), delta.PatchedData)
+	}
+}
+
+func Test_VerifyPackUnknownType(t *testing.T) {
+	pack, idx := readTestPack(t)
+
+	// change the type of the blob af6e4fe (at offset 1002) to 5,
+	// which is reserved
+	const offset = 1002
+	if packObjectType((pack[offset]>>4)&7) != OBJ_BLOB {
+		t.Fatalf("expected blob at offset %d", offset)
+	}
+	pack[offset] = pack[offset]&0x8f | 5<<4
+
+	_, err := VerifyPack(bytes.NewReader(pack), bytes.NewReader(idx))
+	if !errors.Is(err, ErrUnknownObjectType) {
+		t.Errorf("expected ErrUnknownObjectType and received %v", err)
+	}
+
+	objects, err := VerifyPackLenient(bytes.NewReader(pack), bytes.NewReader(idx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 17 {
+		t.Errorf("expected 17 objects and received %d", len(objects))
+	}
+	for _, object := range objects {
+		if object.Name == "af6e4fe91a8f9a0f3c03cbec9e1d2aac47345d67" {
+			if !errors.Is(object.err, ErrUnknownObjectType) {
+				t.Errorf("expected ErrUnknownObjectType and received %v", object.err)
+			}
+			continue
+		}
+		if object.err != nil {
+			t.Errorf("%s: %s", object.Name, object.err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package gitgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrTagTargetMissing is returned by VerifyTag when
+	// the object that a tag points to does not exist
+	ErrTagTargetMissing = errors.New("tag target does not exist")
+
+	// ErrTagTypeMismatch is returned by VerifyTag when the object
+	// that a tag points to is not of the type named by the tag
+	ErrTagTypeMismatch = errors.New("tag target has the wrong type")
+
+	// ErrInvalidTagger is returned by VerifyTag when
+	// the tagger line is missing or malformed
+	ErrInvalidTagger = errors.New("invalid tagger")
+
+	// ErrBadSignature is returned by VerifyTagSigned when
+	// the tag's signature does not verify
+	ErrBadSignature = errors.New("bad signature")
+)
+
+// A Keyring verifies detached signatures, such as the OpenPGP
+// or SSH signatures that git appends to signed tags
+type Keyring interface {
+	// Verify returns an error unless signature is a valid
+	// signature of payload by one of the keyring's keys
+	Verify(payload, signature []byte) error
+}
+
+// signatureHeaders begin each kind of signature that git
+// appends to the message of a signed tag
+var signatureHeaders = []string{
+	"-----BEGIN PGP SIGNATURE-----",
+	"-----BEGIN PGP MESSAGE-----",
+	"-----BEGIN SSH SIGNATURE-----",
+	"-----BEGIN SIGNED MESSAGE-----",
+}
+
+// VerifyTag checks that the object tag points to exists and has the
+// type named by the tag, and that the tagger line is well-formed.
+// It returns ErrTagTargetMissing, ErrTagTypeMismatch, or ErrInvalidTagger
+// (wrapped with more detail) if any of these checks fail.
+// The tag's signature (if any) is not checked; see VerifyTagSigned.
+func VerifyTag(repo *Repository, tag Tag) error {
+	return VerifyTagSigned(repo, tag, nil)
+}
+
+// VerifyTagSigned is like VerifyTag, but if the tag is signed and keyring
+// is non-nil, the signature is also verified using keyring. If the
+// signature does not verify, it returns an error wrapping ErrBadSignature.
+func VerifyTagSigned(repo *Repository, tag Tag, keyring Keyring) error {
+	if !validObjectName(tag.Object) {
+		return fmt.Errorf("%w: invalid object name %q", ErrTagTargetMissing, tag.Object)
+	}
+	objType, _, err := repo.rawObject(tag.Object)
+	if errors.Is(err, ErrObjectNotFound) {
+		return fmt.Errorf("%w: %s", ErrTagTargetMissing, tag.Object)
+	}
+	if err != nil {
+		return err
+	}
+	if objType != tag.ObjectType {
+		return fmt.Errorf("%w: %s is a %s, not a %s", ErrTagTypeMismatch, tag.Object, objType, tag.ObjectType)
+	}
+
+	if err := checkTagger(tag); err != nil {
+		return err
+	}
+
+	if keyring == nil {
+		return nil
+	}
+	_, data, err := repo.rawObject(tag.Name)
+	if err != nil {
+		return err
+	}
+	payload, signature := splitSignature(data)
+	if signature == nil {
+		return nil
+	}
+	if err := keyring.Verify(payload, signature); err != nil {
+		return fmt.Errorf("%w for tag %s: %s", ErrBadSignature, tag.Name, err)
+	}
+	return nil
+}
+
+// checkTagger checks that the tag has a tagger of the form
+// "Name <email>", and a tagger date
+func checkTagger(tag Tag) error {
+	tagger := tag.Tagger
+	open := strings.Index(tagger, "<")
+	if tagger == "" || open < 0 || !strings.HasSuffix(tagger, ">") ||
+		strings.Count(tagger, "<") != 1 || strings.Count(tagger, ">") != 1 ||
+		strings.ContainsAny(tagger, "\n\x00") {
+		return fmt.Errorf("%w: %q", ErrInvalidTagger, tagger)
+	}
+	if open > 0 && tagger[open-1] != ' ' {
+		return fmt.Errorf("%w: missing space before email: %q", ErrInvalidTagger, tagger)
+	}
+	if tag.TaggerDate.IsZero() {
+		return fmt.Errorf("%w: missing date", ErrInvalidTagger)
+	}
+	return nil
+}
+
+// splitSignature splits the contents of a tag object into the signed
+// payload and the signature that follows it. If the tag is not signed,
+// the signature is nil.
+func splitSignature(data []byte) (payload, signature []byte) {
+	start := -1
+	for _, header := range signatureHeaders {
+		// the signature begins on its own line
+		i := bytes.LastIndex(data, []byte("\n"+header))
+		if i >= 0 && i+1 > start {
+			start = i + 1
+		}
+	}
+	if start < 0 {
+		return data, nil
+	}
+	return data[:start], data[start:]
+}
+
+// validObjectName returns true if name is a full (unabbreviated)
+// SHA-1 or SHA-256 object name
+func validObjectName(name SHA) bool {
+	if len(name) != 40 && len(name) != 64 {
+		return false
+	}
+	return strings.Trim(string(name), "0123456789abcdef") == ""
+}
@@ -0,0 +1,87 @@
+package gitgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// testKeyring accepts a single signature over a single payload
+type testKeyring struct {
+	payload   []byte
+	signature []byte
+}
+
+func (k testKeyring) Verify(payload, signature []byte) error {
+	if !bytes.Equal(payload, k.payload) || !bytes.Equal(signature, k.signature) {
+		return fmt.Errorf("signature does not match")
+	}
+	return nil
+}
+
+func Test_VerifyTag(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	obj, err := repo.Object("49bac2b0a923fe6481c7cc207837cf663748c1ed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := obj.(Tag)
+	if err := VerifyTag(repo, tag); err != nil {
+		t.Errorf("unexpected error for a valid tag: %s", err)
+	}
+
+	missing := tag
+	missing.Object = "0000000000000000000000000000000000000000"
+	if err := VerifyTag(repo, missing); !errors.Is(err, ErrTagTargetMissing) {
+		t.Errorf("expected ErrTagTargetMissing and received %v", err)
+	}
+
+	mismatch := tag
+	mismatch.ObjectType = "tree"
+	if err := VerifyTag(repo, mismatch); !errors.Is(err, ErrTagTypeMismatch) {
+		t.Errorf("expected ErrTagTypeMismatch and received %v", err)
+	}
+
+	for _, tagger := range []string{"", "aditya", "aditya <dev@chimeracoder.net", "aditya<dev@chimeracoder.net>", "a <b> <c>"} {
+		invalid := tag
+		invalid.Tagger = tagger
+		if err := VerifyTag(repo, invalid); !errors.Is(err, ErrInvalidTagger) {
+			t.Errorf("%q: expected ErrInvalidTagger and received %v", tagger, err)
+		}
+	}
+}
+
+func Test_VerifyTagSigned(t *testing.T) {
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	payload := []byte("object 37213e7bb3c334a0f7708c7afcab5babb3f95434\n" +
+		"type commit\n" +
+		"tag 0.2\n" +
+		"tagger aditya <dev@chimeracoder.net> 1428612007 -0400\n" +
+		"\n" +
+		"Signed release\n")
+	signature := []byte("-----BEGIN PGP SIGNATURE-----\n\nnot a real signature\n-----END PGP SIGNATURE-----\n")
+	name := writeTestObject(t, repo, OBJ_TAG, append(append([]byte{}, payload...), signature...))
+
+	obj, err := repo.Object(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := obj.(Tag)
+
+	if err := VerifyTagSigned(repo, tag, testKeyring{payload, signature}); err != nil {
+		t.Errorf("unexpected error for a valid signature: %s", err)
+	}
+	if err := VerifyTagSigned(repo, tag, testKeyring{payload, []byte("another signature")}); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("expected ErrBadSignature and received %v", err)
+	}
+
+	// without a keyring, the signature is not checked
+	if err := VerifyTag(repo, tag); err != nil {
+		t.Errorf("unexpected error without a keyring: %s", err)
+	}
+}